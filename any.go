@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// anyTypes maps registered type URLs to their decode functions
+var anyTypes sync.Map
+
+// RegisterAnyType sets how DecodeAny decodes the value of an Any with the
+// given type URL, replacing any earlier registration
+func RegisterAnyType(typeURL string, decode func([]byte) (*Message, error)) {
+	anyTypes.Store(typeURL, decode)
+}
+
+// ParseAny splits a google.protobuf.Any field into its type URL (field 1)
+// and encoded value (field 2)
+func ParseAny(f Field) (string, []byte, error) {
+	var raw []byte
+	switch {
+	case f.message != nil:
+		var err error
+		if raw, err = f.messageBytes(); err != nil {
+			return "", nil, err
+		}
+	case f.bytes != nil, f.string != nil:
+		raw = f.BytesUnsafe()
+	default:
+		return "", nil, fmt.Errorf("Any must be a length delimited field")
+	}
+	msg, _, err := ParseProto(raw, WithForceString(1), WithForceBytes(2))
+	if err != nil {
+		return "", nil, fmt.Errorf("Invalid Any: %v", err)
+	}
+	urls := (*msg)[1]
+	if len(urls) == 0 || urls[0].string == nil {
+		return "", nil, fmt.Errorf("Invalid Any, missing the type URL in field 1")
+	}
+	var value []byte
+	if values := (*msg)[2]; len(values) > 0 {
+		if values[0].bytes == nil {
+			return "", nil, fmt.Errorf("Invalid Any, field 2 must be bytes")
+		}
+		value = *values[0].bytes
+	}
+	return *urls[0].string, value, nil
+}
+
+// DecodeAny is like ParseAny but decodes the value with the function
+// registered for its type URL
+func DecodeAny(f Field) (*Message, error) {
+	typeURL, value, err := ParseAny(f)
+	if err != nil {
+		return nil, err
+	}
+	decode, ok := anyTypes.Load(typeURL)
+	if !ok {
+		return nil, fmt.Errorf("No decoder registered for Any type %s", typeURL)
+	}
+	return decode.(func([]byte) (*Message, error))(value)
+}
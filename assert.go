@@ -0,0 +1,59 @@
+//go:build grpctest
+
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// AssertEqual fails t with each differing field if expected and actual
+// are not equal. The assert helpers are only built with the grpctest build
+// tag, so that the CLI doesn't link the testing package.
+func AssertEqual(t testing.TB, expected, actual *Message) {
+	t.Helper()
+	diffs := Diff(expected, actual)
+	if len(diffs) == 0 {
+		return
+	}
+	t.Errorf("Messages differ:\n%s", RenderDiff(diffs))
+}
+
+// AssertEqualPath fails t unless the first field at a path such as "1.2" or
+// "1[2]" has the expected value, which must be a uint64, string or []byte
+func AssertEqualPath(t testing.TB, m *Message, path string, expected interface{}) {
+	t.Helper()
+	elems, err := parsePath(path)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	fields := getPath(m, elems)
+	if len(fields) == 0 {
+		t.Errorf("Field %s: expected %#v but the field is missing", path, expected)
+		return
+	}
+	f := fields[0]
+	switch expected := expected.(type) {
+	case uint64:
+		if f.numeric == nil {
+			t.Errorf("Field %s: expected %d but found %s", path, expected, renderDiffValue(0, f))
+		} else if *f.numeric != expected {
+			t.Errorf("Field %s: expected %d but found %d", path, expected, *f.numeric)
+		}
+	case string:
+		if f.string == nil {
+			t.Errorf("Field %s: expected %q but found %s", path, expected, renderDiffValue(0, f))
+		} else if *f.string != expected {
+			t.Errorf("Field %s: expected %q but found %q", path, expected, *f.string)
+		}
+	case []byte:
+		if f.bytes == nil && f.string == nil {
+			t.Errorf("Field %s: expected %x but found %s", path, expected, renderDiffValue(0, f))
+		} else if actual := f.BytesUnsafe(); !bytes.Equal(actual, expected) {
+			t.Errorf("Field %s: expected %x but found %x", path, expected, actual)
+		}
+	default:
+		t.Errorf("Field %s: cannot compare with a %T, expected a uint64, string or []byte", path, expected)
+	}
+}
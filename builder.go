@@ -0,0 +1,70 @@
+package main
+
+import "math"
+
+type MessageBuilder struct {
+	msg Message
+}
+
+func NewMessageBuilder() *MessageBuilder {
+	return &MessageBuilder{msg: NewMessage()}
+}
+
+// set replaces any values already set for id
+func (b *MessageBuilder) set(id uint64, f Field) *MessageBuilder {
+	if b.msg == nil {
+		b.msg = NewMessage()
+	}
+	b.msg[id] = []Field{f}
+	return b
+}
+
+func (b *MessageBuilder) SetUint64(id uint64, v uint64) *MessageBuilder {
+	return b.set(id, Field{numeric: &v, wireType: Varint})
+}
+
+func (b *MessageBuilder) SetInt64(id uint64, v int64) *MessageBuilder {
+	x := uint64(v)
+	return b.set(id, Field{numeric: &x, wireType: Varint})
+}
+
+func (b *MessageBuilder) SetFloat32(id uint64, v float32) *MessageBuilder {
+	x := uint64(math.Float32bits(v))
+	return b.set(id, Field{numeric: &x, wireType: B32})
+}
+
+func (b *MessageBuilder) SetFloat64(id uint64, v float64) *MessageBuilder {
+	x := math.Float64bits(v)
+	return b.set(id, Field{numeric: &x, wireType: B64})
+}
+
+func (b *MessageBuilder) SetString(id uint64, v string) *MessageBuilder {
+	return b.set(id, Field{string: &v, wireType: LengthDelim})
+}
+
+func (b *MessageBuilder) SetBytes(id uint64, v []byte) *MessageBuilder {
+	v = append([]byte{}, v...)
+	return b.set(id, Field{bytes: &v, wireType: LengthDelim})
+}
+
+func (b *MessageBuilder) SetMessage(id uint64, sub *MessageBuilder) *MessageBuilder {
+	return b.set(id, Field{message: sub.Build(), wireType: LengthDelim})
+}
+
+func (b *MessageBuilder) AddRepeated(id uint64, f Field) *MessageBuilder {
+	if b.msg == nil {
+		b.msg = NewMessage()
+	}
+	b.msg.Add(id, f)
+	return b
+}
+
+// Build returns a snapshot of the message, so the builder can keep being
+// used without changing messages it already built
+func (b *MessageBuilder) Build() *Message {
+	msg := NewMessage()
+	for id, fields := range b.msg {
+		msg[id] = append([]Field{}, fields...)
+	}
+	return &msg
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ParseProtoCallback calls cb for each top-level field of data without
+// building a Message. raw is the encoded value: the varint bytes, the 4 or
+// 8 fixed bytes, the content of a length-delimited field without its
+// length, or the contents of a group without its end tag. raw shares
+// memory with data. Parsing stops at the first error cb returns.
+func ParseProtoCallback(data []byte, cb func(tag *Tag, raw []byte) error) error {
+	for pos := 0; pos < len(data); {
+		tag, n, err := ParseTag(data[pos:])
+		if err != nil {
+			return err
+		}
+		pos += n
+		var raw []byte
+		switch tag.typ {
+		case LengthDelim:
+			x, n, err := consumeVarint(data[pos:])
+			if err != nil {
+				return err
+			}
+			pos += n
+			if err := checkLengthDelim(x); err != nil {
+				return err
+			}
+			if uint64(len(data)-pos) < x {
+				return fmt.Errorf("Not enough bytes for length delimited field, wanted %d but only found %d", x, len(data)-pos)
+			}
+			raw = data[pos : pos+int(x)]
+			pos += int(x)
+		case EGroup:
+			return fmt.Errorf("Unexpected end of group for field %d", tag.fieldID)
+		default:
+			m := protowire.ConsumeFieldValue(protowire.Number(tag.fieldID), protowire.Type(tag.typ), data[pos:])
+			if m < 0 {
+				return protowire.ParseError(m)
+			}
+			raw = data[pos : pos+m]
+			if tag.typ == SGroup {
+				raw = raw[:m-protowire.SizeTag(protowire.Number(tag.fieldID))]
+			}
+			pos += m
+		}
+		if err := cb(tag, raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import "google.golang.org/protobuf/encoding/protowire"
+
+// Canonicalize encodes m so that messages with the same content always
+// encode to the same bytes: fields in ascending ID order, minimal varints,
+// repeated numeric fields packed and sub-messages canonicalized in turn.
+// Packed fields re-parse as bytes or sub-messages unless listed in
+// ParseOptions.PackedFields.
+func Canonicalize(m *Message) ([]byte, error) {
+	return appendCanonical(nil, m)
+}
+
+func appendCanonical(b []byte, m *Message) ([]byte, error) {
+	if m == nil {
+		return b, nil
+	}
+	for _, id := range m.FieldIDs() {
+		fields := (*m)[id]
+		num := protowire.Number(id)
+		if packable(fields) {
+			var packed []byte
+			for _, f := range fields {
+				switch f.wireType {
+				case B32:
+					packed = protowire.AppendFixed32(packed, uint32(*f.numeric))
+				case B64:
+					packed = protowire.AppendFixed64(packed, *f.numeric)
+				default:
+					packed = protowire.AppendVarint(packed, *f.numeric)
+				}
+			}
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			b = protowire.AppendBytes(b, packed)
+			continue
+		}
+		for _, f := range fields {
+			var err error
+			switch {
+			case f.message != nil && f.wireType == SGroup:
+				b = protowire.AppendTag(b, num, protowire.StartGroupType)
+				if b, err = appendCanonical(b, f.message); err != nil {
+					return nil, err
+				}
+				b = protowire.AppendTag(b, num, protowire.EndGroupType)
+			case f.message != nil:
+				sub, err := appendCanonical(nil, f.message)
+				if err != nil {
+					return nil, err
+				}
+				b = protowire.AppendTag(b, num, protowire.BytesType)
+				b = protowire.AppendBytes(b, sub)
+			default:
+				if b, err = appendField(b, id, f); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return b, nil
+}
+
+// packable reports whether a repeated field is made of numeric values of a
+// single wire type
+func packable(fields []Field) bool {
+	if len(fields) < 2 {
+		return false
+	}
+	for _, f := range fields {
+		if f.numeric == nil || f.wireType != fields[0].wireType {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type input struct {
+	name string
+	data []byte
+}
+
+func readInputs(paths []string) ([]input, error) {
+	if len(paths) == 0 {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read input: %v", err)
+		}
+		return []input{{name: "-", data: data}}, nil
+	}
+	inputs := make([]input, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read input: %v", err)
+		}
+		inputs = append(inputs, input{name: path, data: data})
+	}
+	return inputs, nil
+}
+
+type output struct {
+	w    io.Writer
+	file *os.File
+	err  error
+}
+
+func openOutput(path string, appendOutput bool) (*output, error) {
+	if path == "-" || path == "" {
+		if appendOutput {
+			return nil, fmt.Errorf("--append requires --output with a file path")
+		}
+		return &output{w: os.Stdout}, nil
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendOutput {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	f, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open output: %v", err)
+	}
+	return &output{w: f, file: f}, nil
+}
+
+// Write remembers the first error so that a failed write is reported once
+// output is closed instead of being lost in a fmt.Fprint call
+func (o *output) Write(p []byte) (int, error) {
+	if o.err != nil {
+		return 0, o.err
+	}
+	n, err := o.w.Write(p)
+	o.err = err
+	return n, err
+}
+
+func (o *output) Close() error {
+	if o.file == nil {
+		return o.err
+	}
+	f := o.file
+	o.file = nil
+	if err := f.Close(); err != nil && o.err == nil {
+		o.err = err
+	}
+	return o.err
+}
+
+func closeOutput(out *output) {
+	if err := out.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write output: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func parseFrameRange(frame int, frames string) (int, int, error) {
+	if frame != 0 && frames != "" {
+		return 0, 0, fmt.Errorf("--frame and --frames cannot be used together")
+	}
+	if frame != 0 {
+		if frame < 1 {
+			return 0, 0, fmt.Errorf("Invalid --frame %d, frames are numbered from 1", frame)
+		}
+		return frame, frame, nil
+	}
+	if frames == "" {
+		return 1, 0, nil
+	}
+	var first, last int
+	if _, err := fmt.Sscanf(frames, "%d-%d", &first, &last); err != nil {
+		return 0, 0, fmt.Errorf("Invalid --frames %q, expected M-N", frames)
+	}
+	if first < 1 || last < first {
+		return 0, 0, fmt.Errorf("Invalid --frames %q, expected 1 <= M <= N", frames)
+	}
+	return first, last, nil
+}
+
+func selectFrames(data []byte, first, last int, opts ParseOptions) ([]*Message, error) {
+	data, offset, err := SkipGrpcFrames(data, first-1)
+	if err != nil {
+		return nil, fmt.Errorf("Frame %d not found in input: %v", first, err)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("Frame %d not found in input", first)
+	}
+	msgs := []*Message{}
+	for n := first; len(data) > 0 && (last == 0 || n <= last); n++ {
+		msg, size, err := ParseGrpcWithOptions(data, opts.at(offset))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse gRPC frame %d: %v", n, err)
+		}
+		if msg == nil {
+			return nil, fmt.Errorf("Failed to parse gRPC frame %d: no message found", n)
+		}
+		msgs = append(msgs, msg)
+		data = data[size:]
+		offset += size
+		if len(data) == 0 && last != 0 && n < last {
+			return nil, fmt.Errorf("Frame %d not found in input, only %d frames available", last, n)
+		}
+	}
+	return msgs, nil
+}
+
+// parseTypeHints parses "id:type,..." into render hints for numeric types
+// plus parse overrides for string, bytes and message
+func parseTypeHints(s string, popts *ParseOptions) (map[uint64]FieldType, error) {
+	hints := map[uint64]FieldType{}
+	if s == "" {
+		return hints, nil
+	}
+	for _, hint := range strings.Split(s, ",") {
+		parts := strings.SplitN(hint, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid type hint %q, expected field_id:type", hint)
+		}
+		id, err := strconv.ParseUint(strings.TrimSpace(parts[0]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid type hint %q, %q is not a field ID", hint, parts[0])
+		}
+		t, err := ParseFieldType(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid type hint %q: %v", hint, err)
+		}
+		addTypeHint(id, t, hints, popts)
+	}
+	return hints, nil
+}
+
+func addTypeHint(id uint64, t FieldType, hints map[uint64]FieldType, popts *ParseOptions) {
+	switch t {
+	case TypeString:
+		popts.ForceString = append(popts.ForceString, id)
+	case TypeBytes:
+		popts.ForceBytes = append(popts.ForceBytes, id)
+	case TypeMessage:
+		popts.ForceMessage = append(popts.ForceMessage, id)
+	default:
+		hints[id] = t
+	}
+}
+
+// loadSchemaFile reads a JSON file mapping field IDs to a name and an
+// optional type, as in {"1": {"name": "user_id", "type": "string"}}, adding
+// the types to hints and popts like --type-hints does
+func loadSchemaFile(path string, hints map[uint64]FieldType, popts *ParseOptions) (map[uint64]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var schema map[string]struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("Invalid schema file %s: %v", path, err)
+	}
+	names := map[uint64]string{}
+	for key, field := range schema {
+		id, err := strconv.ParseUint(key, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid schema file %s: %q is not a field ID", path, key)
+		}
+		if field.Name != "" {
+			names[id] = field.Name
+		}
+		if field.Type != "" {
+			t, err := ParseFieldType(field.Type)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid schema file %s: field %d: %v", path, id, err)
+			}
+			addTypeHint(id, t, hints, popts)
+		}
+	}
+	return names, nil
+}
+
+// useColor decides whether to color output for a --color mode, where auto
+// colors only a terminal on stdout and respects NO_COLOR
+func useColor(mode, output string) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+		if output != "-" || os.Getenv("NO_COLOR") != "" {
+			return false, nil
+		}
+		info, err := os.Stdout.Stat()
+		return err == nil && info.Mode()&os.ModeCharDevice != 0, nil
+	default:
+		return false, fmt.Errorf("Invalid --color %q, expected auto, always or never", mode)
+	}
+}
+
+func countSet(flags ...bool) int {
+	n := 0
+	for _, f := range flags {
+		if f {
+			n++
+		}
+	}
+	return n
+}
+
+func unescapeFlag(s string) string {
+	if u, err := strconv.Unquote("\"" + s + "\""); err == nil {
+		return u
+	}
+	return s
+}
+
+// toGrpcFrames converts input of any detected encoding into plain gRPC
+// frames, dropping a gRPC-Web trailer frame
+func toGrpcFrames(data []byte, opts ParseOptions) ([]byte, error) {
+	switch detectEncoding(data, opts) {
+	case EncodingGrpc:
+		return data, nil
+	case EncodingProto:
+		frame := []byte{0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(frame[1:], uint32(len(data)))
+		return append(frame, data...), nil
+	case EncodingGrpcWeb:
+		for pos := 0; ; {
+			if data[pos] == grpcWebTrailerFlag {
+				return data[:pos], nil
+			}
+			pos += 5 + int(binary.BigEndian.Uint32(data[pos+1:pos+5]))
+		}
+	case EncodingBase64GrpcWeb:
+		decoded, _ := decodeBase64Input(data)
+		return toGrpcFrames(decoded, opts)
+	default:
+		return nil, fmt.Errorf("Could not detect the input encoding")
+	}
+}
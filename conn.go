@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+)
+
+// ParseGrpcConn reads and parses exactly one gRPC frame from conn, blocking
+// until the whole frame arrives. It returns io.EOF if conn is closed before
+// the frame starts.
+func ParseGrpcConn(conn net.Conn, opts ...ParseOption) (*Message, error) {
+	o := applyOptions(opts)
+	header := make([]byte, 5)
+	if n, err := io.ReadFull(conn, header); err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, fmt.Errorf("Incomplete gRPC frame header, only read %d of 5 bytes: %w", n, err)
+	}
+	size, err := parseGrpcHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	// check the size before allocating for it
+	if o.MaxSize > 0 && uint64(size) > uint64(o.MaxSize) {
+		return nil, &limitError{limit: "size", max: o.MaxSize}
+	}
+	payload := make([]byte, size)
+	if n, err := io.ReadFull(conn, payload); err != nil {
+		return nil, fmt.Errorf("Incomplete gRPC frame, wanted %d bytes but only found %d: %w", size, n, err)
+	}
+	msg, _, err := ParseProtoWithOptions(payload, o.at(5))
+	return msg, err
+}
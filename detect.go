@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+)
+
+type Encoding int
+
+const (
+	EncodingUnknown Encoding = iota
+	EncodingGrpc
+	EncodingProto
+	EncodingGrpcWeb
+	EncodingBase64GrpcWeb
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case EncodingGrpc:
+		return "grpc"
+	case EncodingProto:
+		return "proto"
+	case EncodingGrpcWeb:
+		return "grpc-web"
+	case EncodingBase64GrpcWeb:
+		return "grpc-web-text"
+	default:
+		return "unknown"
+	}
+}
+
+// DetectEncoding guesses how data is encoded. Framing is tried first since
+// it is the most constrained: every frame header must have a valid flag and
+// the lengths must add up to exactly the input size.
+func DetectEncoding(data []byte) Encoding {
+	return detectEncoding(data, DefaultParseOptions())
+}
+
+// detectEncoding is DetectEncoding with the limits of opts applied to every
+// payload it tries to parse
+func detectEncoding(data []byte, opts ParseOptions) Encoding {
+	if len(data) == 0 {
+		return EncodingUnknown
+	}
+	if enc := detectFraming(data, opts); enc != EncodingUnknown {
+		return enc
+	}
+	if decoded, ok := decodeBase64Input(data); ok && detectFraming(decoded, opts) != EncodingUnknown {
+		return EncodingBase64GrpcWeb
+	}
+	if _, _, err := ParseProtoWithOptions(data, opts); err == nil {
+		return EncodingProto
+	}
+	return EncodingUnknown
+}
+
+// detectFraming returns EncodingGrpc or EncodingGrpcWeb if data is a
+// sequence of well-formed frames with parseable payloads, where gRPC-Web
+// ends with a trailer frame
+func detectFraming(data []byte, opts ParseOptions) Encoding {
+	enc := EncodingGrpc
+	for len(data) > 0 {
+		if len(data) < 5 || enc == EncodingGrpcWeb {
+			return EncodingUnknown
+		}
+		flag := data[0]
+		size := binary.BigEndian.Uint32(data[1:5])
+		if uint64(len(data)-5) < uint64(size) {
+			return EncodingUnknown
+		}
+		payload := data[5 : 5+int(size)]
+		switch flag {
+		case 0:
+			if _, _, err := ParseProtoWithOptions(payload, opts); err != nil {
+				return EncodingUnknown
+			}
+		case 1:
+		case grpcWebTrailerFlag:
+			if _, err := ParseGrpcTrailer(payload); err != nil {
+				return EncodingUnknown
+			}
+			enc = EncodingGrpcWeb
+		default:
+			return EncodingUnknown
+		}
+		data = data[5+int(size):]
+	}
+	return enc
+}
+
+func decodeBase64Input(data []byte) ([]byte, bool) {
+	text := string(bytes.Join(bytes.Fields(data), nil))
+	if decoded, err := base64.StdEncoding.DecodeString(text); err == nil {
+		return decoded, true
+	}
+	if decoded, err := base64.RawStdEncoding.DecodeString(text); err == nil {
+		return decoded, true
+	}
+	return nil, false
+}
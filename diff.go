@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+type DiffKind int
+
+const (
+	DiffAdded DiffKind = iota
+	DiffRemoved
+	DiffChanged
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case DiffAdded:
+		return "+"
+	case DiffRemoved:
+		return "-"
+	case DiffChanged:
+		return "~"
+	default:
+		return "?"
+	}
+}
+
+type Difference struct {
+	Path   string
+	Kind   DiffKind
+	Before string
+	After  string
+}
+
+func (d Difference) String() string {
+	switch d.Kind {
+	case DiffAdded:
+		return fmt.Sprintf("%s %s: %s", d.Kind, d.Path, d.After)
+	case DiffRemoved:
+		return fmt.Sprintf("%s %s: %s", d.Kind, d.Path, d.Before)
+	default:
+		return fmt.Sprintf("%s %s: %s -> %s", d.Kind, d.Path, d.Before, d.After)
+	}
+}
+
+// ANSI colors for RenderDiffColor
+const (
+	colorReset  = "\x1b[0m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+)
+
+func (k DiffKind) color() string {
+	switch k {
+	case DiffAdded:
+		return colorGreen
+	case DiffRemoved:
+		return colorRed
+	default:
+		return colorYellow
+	}
+}
+
+func Diff(a, b *Message) []Difference {
+	return diffMessages(a, b, "")
+}
+
+func diffMessages(a, b *Message, prefix string) []Difference {
+	if a == nil {
+		empty := NewMessage()
+		a = &empty
+	}
+	if b == nil {
+		empty := NewMessage()
+		b = &empty
+	}
+	union := NewMessage()
+	Merge(&union, a)
+	Merge(&union, b)
+	diffs := []Difference{}
+	for _, id := range union.FieldIDs() {
+		as, bs := (*a)[id], (*b)[id]
+		n := len(as)
+		if len(bs) > n {
+			n = len(bs)
+		}
+		for i := 0; i < n; i++ {
+			path := fmt.Sprintf("%s%d", prefix, id)
+			if n > 1 {
+				path = fmt.Sprintf("%s[%d]", path, i)
+			}
+			switch {
+			case i >= len(as):
+				diffs = append(diffs, Difference{Path: path, Kind: DiffAdded, After: renderDiffValue(id, bs[i])})
+			case i >= len(bs):
+				diffs = append(diffs, Difference{Path: path, Kind: DiffRemoved, Before: renderDiffValue(id, as[i])})
+			case as[i].message != nil && bs[i].message != nil:
+				diffs = append(diffs, diffMessages(as[i].message, bs[i].message, path+".")...)
+			default:
+				before, after := renderDiffValue(id, as[i]), renderDiffValue(id, bs[i])
+				if before != after {
+					diffs = append(diffs, Difference{Path: path, Kind: DiffChanged, Before: before, After: after})
+				}
+			}
+		}
+	}
+	return diffs
+}
+
+func renderDiffValue(id uint64, f Field) string {
+	return RenderFieldWithOptions(id, f, RenderOptions{Sorted: true})
+}
+
+func RenderDiff(diffs []Difference) string {
+	lines := make([]string, len(diffs))
+	for i, d := range diffs {
+		lines[i] = d.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// RenderDiffColor is like RenderDiff with ANSI colors: green for added
+// fields, red for removed ones and yellow for changed values
+func RenderDiffColor(diffs []Difference) string {
+	lines := make([]string, len(diffs))
+	for i, d := range diffs {
+		lines[i] = d.Kind.color() + d.String() + colorReset
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// dumpFieldLimit is how many field IDs a dump summary line lists
+const dumpFieldLimit = 3
+
+type DumpOptions struct {
+	// Verbose also renders each frame in full
+	Verbose bool
+	// Direction, such as "request" or "response", labels every frame
+	Direction string
+	// MaxFrames stops the dump after this many frames if non-zero
+	MaxFrames int
+	// ParseOptions are used to parse each uncompressed frame
+	ParseOptions ParseOptions
+	// HumanReadable shows frame sizes with binary prefixes, or decimal
+	// ones if SI is also set
+	HumanReadable bool
+	SI            bool
+}
+
+// GrpcDump writes a one-line summary of each gRPC frame read from r,
+// followed by a count of the frames dumped
+func GrpcDump(r io.Reader, w io.Writer, opts DumpOptions) error {
+	out := &output{w: w}
+	frames := 0
+	for opts.MaxFrames == 0 || frames < opts.MaxFrames {
+		header := make([]byte, 5)
+		if n, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF && n == 0 {
+				break
+			}
+			return fmt.Errorf("Frame %d: Incomplete gRPC frame header: %v", frames+1, err)
+		}
+		compressed, size, err := readGrpcHeader(header)
+		if err != nil {
+			return fmt.Errorf("Frame %d: %v", frames+1, err)
+		}
+		if max := opts.ParseOptions.MaxSize; max > 0 && uint64(size) > uint64(max) {
+			return fmt.Errorf("Frame %d: %v", frames+1, &limitError{limit: "size", max: max})
+		}
+		payload := make([]byte, size)
+		if n, err := io.ReadFull(r, payload); err != nil {
+			return fmt.Errorf("Frame %d: Incomplete gRPC frame, wanted %d bytes but only found %d", frames+1, size, n)
+		}
+		frames++
+		label := fmt.Sprintf("Frame %d", frames)
+		if opts.Direction != "" {
+			label += " " + opts.Direction
+		}
+		sizeText := byteCount{n: int(size), human: opts.HumanReadable, si: opts.SI}.String()
+		line := []string{fmt.Sprintf("%s: %s", label, sizeText)}
+		var msg *Message
+		if compressed {
+			line = append(line, "compressed")
+		} else {
+			line = append(line, "uncompressed")
+			if msg, _, err = ParseProtoWithOptions(payload, opts.ParseOptions); err != nil {
+				return fmt.Errorf("Frame %d: %v", frames, err)
+			}
+			line = append(line, "fields "+summarizeFields(msg))
+		}
+		fmt.Fprintln(out, strings.Join(line, ", "))
+		if opts.Verbose && msg != nil {
+			fmt.Fprintln(out, "  "+RenderSorted(msg))
+		}
+		if out.err != nil {
+			return out.err
+		}
+	}
+	fmt.Fprintf(out, "%d frames\n", frames)
+	return out.err
+}
+
+func summarizeFields(m *Message) string {
+	ids := m.FieldIDs()
+	if len(ids) == 0 {
+		return "none"
+	}
+	summary := []string{}
+	for i, id := range ids {
+		if i == dumpFieldLimit {
+			summary = append(summary, fmt.Sprintf("and %d more", len(ids)-dumpFieldLimit))
+			break
+		}
+		summary = append(summary, fmt.Sprintf("%d (%s)", id, fieldKind((*m)[id][0])))
+	}
+	return strings.Join(summary, ", ")
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func Encode(m *Message) ([]byte, error) {
+	return appendMessage(nil, m)
+}
+
+// appendGrpcFrame appends m as an uncompressed gRPC frame
+func appendGrpcFrame(b []byte, m *Message) ([]byte, error) {
+	start := len(b)
+	b, err := appendMessage(append(b, 0, 0, 0, 0, 0), m)
+	if err != nil {
+		return nil, err
+	}
+	size := len(b) - start - 5
+	if uint64(size) > math.MaxUint32 {
+		return nil, fmt.Errorf("Message is %d bytes, too large for a gRPC frame", size)
+	}
+	binary.BigEndian.PutUint32(b[start+1:start+5], uint32(size))
+	return b, nil
+}
+
+func appendMessage(b []byte, m *Message) ([]byte, error) {
+	if m == nil {
+		return b, nil
+	}
+	for _, id := range m.FieldIDs() {
+		for _, f := range (*m)[id] {
+			var err error
+			b, err = appendField(b, id, f)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return b, nil
+}
+
+func appendField(b []byte, id uint64, f Field) ([]byte, error) {
+	num := protowire.Number(id)
+	switch {
+	case f.numeric != nil:
+		switch f.wireType {
+		case Varint:
+			b = protowire.AppendTag(b, num, protowire.VarintType)
+			b = protowire.AppendVarint(b, *f.numeric)
+		case B32:
+			b = protowire.AppendTag(b, num, protowire.Fixed32Type)
+			b = protowire.AppendFixed32(b, uint32(*f.numeric))
+		case B64:
+			b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+			b = protowire.AppendFixed64(b, *f.numeric)
+		default:
+			return nil, fmt.Errorf("Cannot encode numeric field %d with wire type %d", id, f.wireType)
+		}
+	case f.string != nil:
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		b = protowire.AppendString(b, *f.string)
+	case f.bytes != nil:
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		b = protowire.AppendBytes(b, *f.bytes)
+	case f.message != nil:
+		if f.wireType == SGroup {
+			group, err := appendMessage(protowire.AppendTag(b, num, protowire.StartGroupType), f.message)
+			if err != nil {
+				return nil, err
+			}
+			return protowire.AppendTag(group, num, protowire.EndGroupType), nil
+		}
+		sub, err := Encode(f.message)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		b = protowire.AppendBytes(b, sub)
+	default:
+		return nil, fmt.Errorf("Cannot encode field %d, it has no value", id)
+	}
+	return b, nil
+}
+
+func EncodedSize(m *Message) int {
+	if m == nil {
+		return 0
+	}
+	size := 0
+	for id, fields := range *m {
+		for _, f := range fields {
+			size += encodedFieldSize(id, f)
+		}
+	}
+	return size
+}
+
+func encodedFieldSize(id uint64, f Field) int {
+	tag := protowire.SizeTag(protowire.Number(id))
+	switch {
+	case f.numeric != nil:
+		switch f.wireType {
+		case B32:
+			return tag + protowire.SizeFixed32()
+		case B64:
+			return tag + protowire.SizeFixed64()
+		default:
+			return tag + protowire.SizeVarint(*f.numeric)
+		}
+	case f.string != nil:
+		return tag + protowire.SizeBytes(len(*f.string))
+	case f.bytes != nil:
+		return tag + protowire.SizeBytes(len(*f.bytes))
+	case f.message != nil:
+		if f.wireType == SGroup {
+			return 2*tag + EncodedSize(f.message)
+		}
+		return tag + protowire.SizeBytes(EncodedSize(f.message))
+	}
+	return 0
+}
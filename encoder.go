@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// GrpcEncoder writes messages to w as a stream of uncompressed gRPC frames
+type GrpcEncoder struct {
+	w io.Writer
+}
+
+func NewGrpcEncoder(w io.Writer) *GrpcEncoder {
+	return &GrpcEncoder{w: w}
+}
+
+// WriteMessage encodes m into memory and writes it as a single frame
+func (e *GrpcEncoder) WriteMessage(m *Message) error {
+	frame, err := appendGrpcFrame(nil, m)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(frame)
+	return err
+}
+
+// WriteMessageStreaming writes m one top-level field at a time, so only the
+// largest field is ever held in memory. An encoding error can leave a
+// partial frame written.
+func (e *GrpcEncoder) WriteMessageStreaming(m *Message) error {
+	size := EncodedSize(m)
+	if uint64(size) > math.MaxUint32 {
+		return fmt.Errorf("Message is %d bytes, too large for a gRPC frame", size)
+	}
+	header := []byte{0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(header[1:], uint32(size))
+	if _, err := e.w.Write(header); err != nil {
+		return err
+	}
+	if m == nil {
+		return nil
+	}
+	var buf []byte
+	for _, id := range m.FieldIDs() {
+		for _, f := range (*m)[id] {
+			var err error
+			if buf, err = appendField(buf[:0], id, f); err != nil {
+				return err
+			}
+			if _, err := e.w.Write(buf); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type EnvoyGrpcEntry struct {
+	Method         string
+	StatusCode     int
+	DurationMs     int64
+	RequestBytes   []byte
+	ResponseBytes  []byte
+	ParsedRequest  *Message
+	ParsedResponse *Message
+}
+
+// Envoy access log keys, for a JSON log format or a text format of
+// key=value pairs, that ParseEnvoyGrpcLog understands. The first key found
+// in each list wins. Bodies hold base64 gRPC frames.
+var (
+	envoyMethodKeys   = []string{"grpc_method", "path", ":path"}
+	envoyStatusKeys   = []string{"grpc_status", "response_code"}
+	envoyDurationKeys = []string{"duration", "duration_ms"}
+	envoyRequestKeys  = []string{"request_body", "grpc_request_body"}
+	envoyResponseKeys = []string{"response_body", "grpc_response_body"}
+)
+
+var grpcCodeNames = map[string]int{
+	"OK":                  0,
+	"CANCELLED":           1,
+	"UNKNOWN":             2,
+	"INVALID_ARGUMENT":    3,
+	"DEADLINE_EXCEEDED":   4,
+	"NOT_FOUND":           5,
+	"ALREADY_EXISTS":      6,
+	"PERMISSION_DENIED":   7,
+	"RESOURCE_EXHAUSTED":  8,
+	"FAILED_PRECONDITION": 9,
+	"ABORTED":             10,
+	"OUT_OF_RANGE":        11,
+	"UNIMPLEMENTED":       12,
+	"INTERNAL":            13,
+	"UNAVAILABLE":         14,
+	"DATA_LOSS":           15,
+	"UNAUTHENTICATED":     16,
+}
+
+// ParseEnvoyGrpcLog parses one Envoy access log line, either a JSON object
+// or key=value pairs with optionally quoted values. Missing bodies leave
+// the corresponding bytes and parsed message nil.
+func ParseEnvoyGrpcLog(logLine string) (*EnvoyGrpcEntry, error) {
+	logLine = strings.TrimSpace(logLine)
+	var fields map[string]string
+	var err error
+	if strings.HasPrefix(logLine, "{") {
+		fields, err = parseEnvoyJSON(logLine)
+	} else {
+		fields, err = parseEnvoyText(logLine)
+	}
+	if err != nil {
+		return nil, err
+	}
+	entry := &EnvoyGrpcEntry{
+		Method: envoyLookup(fields, envoyMethodKeys),
+	}
+	if status := envoyLookup(fields, envoyStatusKeys); status != "" {
+		code, ok := grpcCodeNames[strings.ToUpper(status)]
+		if !ok {
+			if code, err = strconv.Atoi(status); err != nil {
+				return nil, fmt.Errorf("Invalid status %q in access log", status)
+			}
+		}
+		entry.StatusCode = code
+	}
+	if duration := envoyLookup(fields, envoyDurationKeys); duration != "" {
+		if entry.DurationMs, err = strconv.ParseInt(duration, 10, 64); err != nil {
+			return nil, fmt.Errorf("Invalid duration %q in access log", duration)
+		}
+	}
+	if entry.RequestBytes, entry.ParsedRequest, err = envoyBody(fields, envoyRequestKeys); err != nil {
+		return nil, fmt.Errorf("Invalid request body: %v", err)
+	}
+	if entry.ResponseBytes, entry.ParsedResponse, err = envoyBody(fields, envoyResponseKeys); err != nil {
+		return nil, fmt.Errorf("Invalid response body: %v", err)
+	}
+	return entry, nil
+}
+
+func parseEnvoyJSON(line string) (map[string]string, error) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &obj); err != nil {
+		return nil, fmt.Errorf("Invalid JSON access log: %v", err)
+	}
+	fields := map[string]string{}
+	for k, v := range obj {
+		switch v := v.(type) {
+		case nil:
+		case string:
+			fields[k] = v
+		default:
+			fields[k] = fmt.Sprint(v)
+		}
+	}
+	return fields, nil
+}
+
+func parseEnvoyText(line string) (map[string]string, error) {
+	fields := map[string]string{}
+	for line != "" {
+		eq := strings.Index(line, "=")
+		if eq <= 0 {
+			return nil, fmt.Errorf("Invalid access log, expected key=value at %q", line)
+		}
+		key := line[:eq]
+		line = line[eq+1:]
+		var value string
+		if strings.HasPrefix(line, `"`) {
+			end := 1
+			for end < len(line) && (line[end] != '"' || line[end-1] == '\\') {
+				end++
+			}
+			if end == len(line) {
+				return nil, fmt.Errorf("Unterminated quoted value for %s in access log", key)
+			}
+			unquoted, err := strconv.Unquote(line[:end+1])
+			if err != nil {
+				return nil, fmt.Errorf("Invalid quoted value for %s in access log: %v", key, err)
+			}
+			value, line = unquoted, line[end+1:]
+		} else if sp := strings.IndexByte(line, ' '); sp >= 0 {
+			value, line = line[:sp], line[sp:]
+		} else {
+			value, line = line, ""
+		}
+		// Envoy writes "-" for empty values
+		if value != "-" {
+			fields[key] = value
+		}
+		line = strings.TrimLeft(line, " ")
+	}
+	return fields, nil
+}
+
+func envoyLookup(fields map[string]string, keys []string) string {
+	for _, k := range keys {
+		if v, ok := fields[k]; ok && v != "" && v != "-" {
+			return v
+		}
+	}
+	return ""
+}
+
+func envoyBody(fields map[string]string, keys []string) ([]byte, *Message, error) {
+	encoded := envoyLookup(fields, keys)
+	if encoded == "" {
+		return nil, nil, nil
+	}
+	body, err := decodeBinaryHeader(encoded)
+	if err != nil {
+		return nil, nil, err
+	}
+	msg, _, err := ParseGrpc(body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, msg, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import "bytes"
+
+// EqualCanonical reports whether the field IDs present in both messages
+// hold the same values in the same order; IDs present in only one message
+// are treated as unknown fields and ignored
+func EqualCanonical(a, b *Message) bool {
+	return equalMessages(a, b, false)
+}
+
+// EqualStrict is like EqualCanonical but also requires both messages to
+// have the same set of field IDs
+func EqualStrict(a, b *Message) bool {
+	return equalMessages(a, b, true)
+}
+
+func equalMessages(a, b *Message, strict bool) bool {
+	if a == nil {
+		empty := NewMessage()
+		a = &empty
+	}
+	if b == nil {
+		empty := NewMessage()
+		b = &empty
+	}
+	if strict && len(*a) != len(*b) {
+		return false
+	}
+	for id, as := range *a {
+		bs, ok := (*b)[id]
+		if !ok {
+			if strict {
+				return false
+			}
+			continue
+		}
+		if len(as) != len(bs) {
+			return false
+		}
+		for i := range as {
+			if !equalFields(as[i], bs[i], strict) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func equalFields(a, b Field, strict bool) bool {
+	switch {
+	case a.numeric != nil && b.numeric != nil:
+		return *a.numeric == *b.numeric && a.wireType == b.wireType
+	case a.string != nil && b.string != nil:
+		return *a.string == *b.string
+	case a.bytes != nil && b.bytes != nil:
+		return bytes.Equal(*a.bytes, *b.bytes)
+	case a.message != nil && b.message != nil:
+		return equalMessages(a.message, b.message, strict)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// explainHexLimit caps the bytes shown in hex on one line of an explanation
+const explainHexLimit = 16
+
+// Explain describes what each byte of a protobuf message means, one line
+// per tag, length or value, with sub-messages indented
+func Explain(data []byte, opts ParseOptions) (string, error) {
+	var sb strings.Builder
+	_, err := explainMessage(&sb, data, 0, "", opts, 0, false)
+	return sb.String(), err
+}
+
+// ExplainGrpc is like Explain for a stream of gRPC frames, also describing
+// each 5-byte frame header
+func ExplainGrpc(data []byte, opts ParseOptions) (string, error) {
+	var sb strings.Builder
+	for offset, frame := 0, 1; offset < len(data); frame++ {
+		rest := data[offset:]
+		if len(rest) < 5 {
+			return sb.String(), fmt.Errorf("Frame %d: Missing gRPC frame size, only %d bytes available", frame, len(rest))
+		}
+		size, err := parseGrpcHeader(rest[:5])
+		if err != nil {
+			return sb.String(), fmt.Errorf("Frame %d: %v", frame, err)
+		}
+		explainLine(&sb, "", data, offset, offset+1, "gRPC compression flag (uncompressed)")
+		explainLine(&sb, "", data, offset+1, offset+5, fmt.Sprintf("gRPC message length = %d", binary.BigEndian.Uint32(rest[1:5])))
+		if uint64(len(rest)-5) < uint64(size) {
+			return sb.String(), fmt.Errorf("Frame %d: Incomplete gRPC frame, wanted %d bytes but only found %d", frame, size, len(rest)-5)
+		}
+		payload := data[:offset+5+int(size)]
+		if _, err := explainMessage(&sb, payload, offset+5, "  ", opts, 0, false); err != nil {
+			return sb.String(), fmt.Errorf("Frame %d: %v", frame, err)
+		}
+		offset += 5 + int(size)
+	}
+	return sb.String(), nil
+}
+
+// explainMessage explains data[start:], where data is kept whole so that
+// offsets are relative to the start of the input
+func explainMessage(sb *strings.Builder, data []byte, start int, indent string, opts ParseOptions, groupID uint64, inGroup bool) (int, error) {
+	pos := start
+	for pos < len(data) {
+		tag, n, err := ParseTag(data[pos:])
+		if err != nil {
+			return 0, err
+		}
+		explainLine(sb, indent, data, pos, pos+n, fmt.Sprintf("tag (field %d, wire type %d: %s)", tag.fieldID, tag.typ, WireTypeName(tag.typ)))
+		pos += n
+		switch tag.typ {
+		case Varint:
+			x, n, err := consumeVarint(data[pos:])
+			if err != nil {
+				return 0, err
+			}
+			explainLine(sb, indent, data, pos, pos+n, fmt.Sprintf("varint %d", x))
+			pos += n
+		case B32:
+			x, n := protowire.ConsumeFixed32(data[pos:])
+			if n < 0 {
+				return 0, protowire.ParseError(n)
+			}
+			explainLine(sb, indent, data, pos, pos+n, fmt.Sprintf("fixed32 %d", x))
+			pos += n
+		case B64:
+			x, n := protowire.ConsumeFixed64(data[pos:])
+			if n < 0 {
+				return 0, protowire.ParseError(n)
+			}
+			explainLine(sb, indent, data, pos, pos+n, fmt.Sprintf("fixed64 %d", x))
+			pos += n
+		case LengthDelim:
+			x, n, err := consumeVarint(data[pos:])
+			if err != nil {
+				return 0, err
+			}
+			explainLine(sb, indent, data, pos, pos+n, fmt.Sprintf("length = %d", x))
+			pos += n
+			if err := checkLengthDelim(x); err != nil {
+				return 0, err
+			}
+			if uint64(len(data)-pos) < x {
+				return 0, fmt.Errorf("Not enough bytes for length delimited field, wanted %d but only found %d", x, len(data)-pos)
+			}
+			end := pos + int(x)
+			field, err := parseLengthDelim(data[pos:end], opts, tag.fieldID)
+			if err != nil {
+				return 0, err
+			}
+			switch {
+			case x == 0:
+				explainLine(sb, indent, data, pos, end, "empty value")
+			case field.message != nil:
+				explainLine(sb, indent, data, pos, end, "sub-message")
+				if _, err := explainMessage(sb, data[:end], pos, indent+"  ", opts.nested(), 0, false); err != nil {
+					return 0, err
+				}
+			case field.string != nil:
+				explainLine(sb, indent, data, pos, end, fmt.Sprintf("%s (UTF-8 string)", quoteString(*field.string)))
+			default:
+				explainLine(sb, indent, data, pos, end, "bytes")
+			}
+			pos = end
+		case SGroup:
+			n, err := explainMessage(sb, data, pos, indent+"  ", opts.nested(), tag.fieldID, true)
+			if err != nil {
+				return 0, err
+			}
+			pos += n
+		case EGroup:
+			if !inGroup {
+				return 0, fmt.Errorf("Unexpected end of group for field %d", tag.fieldID)
+			}
+			if tag.fieldID != groupID {
+				return 0, fmt.Errorf("Mismatched end of group, wanted field %d but found %d", groupID, tag.fieldID)
+			}
+			return pos - start, nil
+		}
+	}
+	if inGroup {
+		return 0, fmt.Errorf("Unclosed group for field %d", groupID)
+	}
+	return pos - start, nil
+}
+
+// explainLine describes data[start:end], showing inclusive offsets
+func explainLine(sb *strings.Builder, indent string, data []byte, start, end int, desc string) {
+	if end == start {
+		fmt.Fprintf(sb, "%sOffset %02d: (no bytes) = %s\n", indent, start, desc)
+		return
+	}
+	b := data[start:end]
+	suffix := ""
+	if len(b) > explainHexLimit {
+		b, suffix = b[:explainHexLimit], "..."
+	}
+	fmt.Fprintf(sb, "%sOffset %02d-%02d: 0x%s%s = %s\n", indent, start, end-1, hex.EncodeToString(b), suffix, desc)
+}
@@ -0,0 +1,35 @@
+package main
+
+import "os"
+
+// ParseGrpcFromFile parses every gRPC frame in a file
+func ParseGrpcFromFile(path string, opts ...ParseOption) ([]*Message, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGrpcStream(data, opts...)
+}
+
+// ParseProtoFromFile parses a file holding a single unframed message
+func ParseProtoFromFile(path string, opts ...ParseOption) (*Message, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	msg, _, err := ParseProto(data, opts...)
+	return msg, err
+}
+
+// WriteGrpcToFile writes msgs to a file as a stream of gRPC frames, which
+// ParseGrpcFromFile reads back
+func WriteGrpcToFile(path string, msgs []*Message) error {
+	var data []byte
+	for _, m := range msgs {
+		var err error
+		if data, err = appendGrpcFrame(data, m); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Filter is a predicate on messages, parsed from expressions such as
+// `field(1) == "hello" && !(field(2.1) > 100)`. field(path) takes a path as
+// for --field and, on its own, is true if the field is present. A
+// comparison is true if any value at the path matches, so it is false for
+// missing fields.
+type Filter struct {
+	root filterNode
+}
+
+func (f *Filter) Match(m *Message) bool {
+	return f.root.eval(m)
+}
+
+type filterNode interface {
+	eval(m *Message) bool
+}
+
+type (
+	filterOr      struct{ a, b filterNode }
+	filterAnd     struct{ a, b filterNode }
+	filterNot     struct{ a filterNode }
+	filterPresent struct{ path []pathElem }
+	filterCompare struct {
+		path []pathElem
+		op   string
+		// value is a uint64 or a string
+		value interface{}
+	}
+)
+
+func (n filterOr) eval(m *Message) bool  { return n.a.eval(m) || n.b.eval(m) }
+func (n filterAnd) eval(m *Message) bool { return n.a.eval(m) && n.b.eval(m) }
+func (n filterNot) eval(m *Message) bool { return !n.a.eval(m) }
+
+func (n filterPresent) eval(m *Message) bool {
+	return len(getPath(m, n.path)) > 0
+}
+
+func (n filterCompare) eval(m *Message) bool {
+	for _, f := range getPath(m, n.path) {
+		var cmp int
+		switch v := n.value.(type) {
+		case uint64:
+			if f.numeric == nil {
+				continue
+			}
+			switch {
+			case *f.numeric < v:
+				cmp = -1
+			case *f.numeric > v:
+				cmp = 1
+			}
+		case string:
+			if f.string == nil && f.bytes == nil {
+				continue
+			}
+			cmp = bytes.Compare(f.BytesUnsafe(), []byte(v))
+		}
+		if compareMatches(n.op, cmp) {
+			return true
+		}
+	}
+	return false
+}
+
+func compareMatches(op string, cmp int) bool {
+	switch op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+func ParseFilter(expr string) (*Filter, error) {
+	p := &filterParser{expr: expr}
+	root, err := p.parseOr()
+	if err == nil && p.peek() != "" {
+		err = fmt.Errorf("unexpected %q", p.peek())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Invalid filter %q at offset %d: %v", expr, p.pos, err)
+	}
+	return &Filter{root: root}, nil
+}
+
+// filterParser is a recursive descent parser for filter expressions, with
+// || binding loosest, then &&, then !
+type filterParser struct {
+	expr string
+	pos  int
+}
+
+var filterOperators = []string{"||", "&&", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")"}
+
+// peek returns the next token without consuming it: an operator, a quoted
+// string, or a run of identifier or number characters
+func (p *filterParser) peek() string {
+	for p.pos < len(p.expr) && (p.expr[p.pos] == ' ' || p.expr[p.pos] == '\t') {
+		p.pos++
+	}
+	rest := p.expr[p.pos:]
+	if rest == "" {
+		return ""
+	}
+	for _, op := range filterOperators {
+		if strings.HasPrefix(rest, op) {
+			return op
+		}
+	}
+	if rest[0] == '"' {
+		for end := 1; end < len(rest); end++ {
+			if rest[end] == '\\' {
+				end++
+			} else if rest[end] == '"' {
+				return rest[:end+1]
+			}
+		}
+		return rest
+	}
+	end := 0
+	for end < len(rest) && strings.IndexByte(" \t|&=!<>()\"", rest[end]) < 0 {
+		end++
+	}
+	if end == 0 {
+		// a lone character that starts no token, such as "=" or "|"
+		return rest[:1]
+	}
+	return rest[:end]
+}
+
+func (p *filterParser) next() string {
+	tok := p.peek()
+	p.pos += len(tok)
+	return tok
+}
+
+func (p *filterParser) expect(tok string) error {
+	if got := p.peek(); got != tok {
+		if got == "" {
+			return fmt.Errorf("expected %q but the filter ended", tok)
+		}
+		return fmt.Errorf("expected %q but found %q", tok, got)
+	}
+	p.next()
+	return nil
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	a, err := p.parseAnd()
+	for err == nil && p.peek() == "||" {
+		p.next()
+		var b filterNode
+		if b, err = p.parseAnd(); err == nil {
+			a = filterOr{a, b}
+		}
+	}
+	return a, err
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	a, err := p.parseUnary()
+	for err == nil && p.peek() == "&&" {
+		p.next()
+		var b filterNode
+		if b, err = p.parseUnary(); err == nil {
+			a = filterAnd{a, b}
+		}
+	}
+	return a, err
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	switch p.peek() {
+	case "!":
+		p.next()
+		a, err := p.parseUnary()
+		return filterNot{a}, err
+	case "(":
+		p.next()
+		a, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return a, p.expect(")")
+	}
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (filterNode, error) {
+	if err := p.expect("field"); err != nil {
+		return nil, err
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	path, err := parsePath(p.next())
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	op := p.peek()
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return filterPresent{path}, nil
+	}
+	p.next()
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	return filterCompare{path: path, op: op, value: value}, nil
+}
+
+func (p *filterParser) parseValue() (interface{}, error) {
+	tok := p.next()
+	switch {
+	case tok == "":
+		return nil, fmt.Errorf("expected a value but the filter ended")
+	case tok[0] == '"':
+		if len(tok) < 2 || tok[len(tok)-1] != '"' {
+			return nil, fmt.Errorf("unterminated string %s", tok)
+		}
+		s, err := strconv.Unquote(tok)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s", tok)
+		}
+		return s, nil
+	}
+	x, err := parseTextInt(tok)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %q, expected a number or a quoted string", tok)
+	}
+	return x, nil
+}
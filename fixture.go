@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// EncodeTestFixture encodes a built message, writing the fields listed in
+// wireTypes with the given wire type instead of the one their content
+// implies. Numeric fields are re-encoded for varint, fixed32 and fixed64,
+// and messages for groups; any other wire type, including invalid ones, is
+// written as-is in the tag in front of the field's usual encoding, for
+// testing how malformed input is handled.
+func EncodeTestFixture(b *MessageBuilder, wireTypes map[uint64]uint64) ([]byte, error) {
+	m := b.Build()
+	var data []byte
+	for _, id := range m.FieldIDs() {
+		for _, f := range (*m)[id] {
+			wt, ok := wireTypes[id]
+			if !ok {
+				var err error
+				if data, err = appendField(data, id, f); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if wt > 7 {
+				return nil, fmt.Errorf("Wire type %d for field %d does not fit in a tag", wt, id)
+			}
+			switch {
+			case f.numeric != nil && (wt == Varint || wt == B32 || wt == B64),
+				f.message != nil && (wt == LengthDelim || wt == SGroup):
+				f.wireType = wt
+				var err error
+				if data, err = appendField(data, id, f); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			natural, err := appendField(nil, id, f)
+			if err != nil {
+				return nil, err
+			}
+			_, _, n := protowire.ConsumeTag(natural)
+			data = protowire.AppendVarint(data, protowire.EncodeTag(protowire.Number(id), protowire.Type(wt)))
+			data = append(data, natural[n:]...)
+		}
+	}
+	return data, nil
+}
+
+// MustEncodeTestFixture is like EncodeTestFixture but panics on error, for
+// building fixtures in package variables or TestMain
+func MustEncodeTestFixture(b *MessageBuilder, wireTypes map[uint64]uint64) []byte {
+	data, err := EncodeTestFixture(b, wireTypes)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
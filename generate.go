@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func GenerateProto(m *Message, msgName, packageName string) string {
+	var sb strings.Builder
+	sb.WriteString("syntax = \"proto3\";\n\n")
+	if packageName != "" {
+		fmt.Fprintf(&sb, "package %s;\n\n", packageName)
+	}
+	if m == nil {
+		empty := NewMessage()
+		m = &empty
+	}
+	generateMessage(&sb, []*Message{m}, msgName, "")
+	return sb.String()
+}
+
+func generateMessage(sb *strings.Builder, msgs []*Message, name, indent string) {
+	fmt.Fprintf(sb, "%smessage %s {\n", indent, name)
+	merged := NewMessage()
+	repeated := map[uint64]bool{}
+	for _, m := range msgs {
+		for id, fields := range *m {
+			if len(fields) > 1 {
+				repeated[id] = true
+			}
+			for _, f := range fields {
+				merged.Add(id, f)
+			}
+		}
+	}
+	nested := []uint64{}
+	for _, id := range merged.FieldIDs() {
+		typ := protoType(merged[id])
+		if typ == "" {
+			typ = fmt.Sprintf("Field%d", id)
+			nested = append(nested, id)
+		}
+		label := ""
+		if repeated[id] {
+			label = "repeated "
+		}
+		fmt.Fprintf(sb, "%s  %s%s field_%d = %d;\n", indent, label, typ, id, id)
+	}
+	for _, id := range nested {
+		subMsgs := []*Message{}
+		for _, f := range merged[id] {
+			subMsgs = append(subMsgs, f.message)
+		}
+		sb.WriteString("\n")
+		generateMessage(sb, subMsgs, fmt.Sprintf("Field%d", id), indent+"  ")
+	}
+	fmt.Fprintf(sb, "%s}\n", indent)
+}
+
+// protoType infers a scalar type from the wire format of a field's values,
+// or returns "" if every value is a sub-message. Values that disagree about
+// their wire format can only be declared as bytes.
+func protoType(fields []Field) string {
+	allNumeric, allMessages, allStrings := true, true, true
+	for _, f := range fields {
+		allNumeric = allNumeric && f.numeric != nil && f.wireType == fields[0].wireType
+		allMessages = allMessages && f.message != nil
+		allStrings = allStrings && f.string != nil
+	}
+	switch {
+	case allNumeric && fields[0].wireType == B32:
+		return "fixed32"
+	case allNumeric && fields[0].wireType == B64:
+		return "fixed64"
+	case allNumeric:
+		return "uint64"
+	case allMessages:
+		return ""
+	case allStrings:
+		return "string"
+	default:
+		return "bytes"
+	}
+}
@@ -0,0 +1,162 @@
+// Package grpctest serves captured gRPC responses for testing clients,
+// interceptors and parsers without a live backend
+package grpctest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// MockGrpcServer answers every call on every stream with the same frames,
+// followed by trailers with grpc-status 0. It speaks HTTP/2 without TLS
+// and without flow control, so the frames of one response must fit in the
+// client's initial 64KB window.
+type MockGrpcServer struct {
+	// Frames are complete gRPC frames, each with its 5-byte header
+	Frames [][]byte
+
+	ln    net.Listener
+	wg    sync.WaitGroup
+	mu    sync.Mutex
+	conns map[net.Conn]bool
+}
+
+// NewMockGrpcServer starts a server on a local port that replays frames
+func NewMockGrpcServer(frames [][]byte) (*MockGrpcServer, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &MockGrpcServer{Frames: frames, ln: ln, conns: map[net.Conn]bool{}}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr is the host:port that clients should dial
+func (s *MockGrpcServer) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Close stops the server and closes every open connection
+func (s *MockGrpcServer) Close() error {
+	err := s.ln.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *MockGrpcServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = true
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+func (s *MockGrpcServer) serveConn(conn net.Conn) error {
+	preface := make([]byte, len(http2.ClientPreface))
+	if _, err := io.ReadFull(conn, preface); err != nil {
+		return err
+	}
+	if string(preface) != http2.ClientPreface {
+		return fmt.Errorf("Invalid HTTP/2 client preface %q", preface)
+	}
+	fr := http2.NewFramer(conn, conn)
+	fr.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+	if err := fr.WriteSettings(); err != nil {
+		return err
+	}
+	var headers bytes.Buffer
+	enc := hpack.NewEncoder(&headers)
+	for {
+		frame, err := fr.ReadFrame()
+		if err != nil {
+			return err
+		}
+		switch f := frame.(type) {
+		case *http2.SettingsFrame:
+			if !f.IsAck() {
+				err = fr.WriteSettingsAck()
+			}
+		case *http2.PingFrame:
+			if !f.IsAck() {
+				err = fr.WritePing(true, f.Data)
+			}
+		case *http2.MetaHeadersFrame:
+			if f.StreamEnded() {
+				err = s.respond(fr, enc, &headers, f.StreamID)
+			}
+		case *http2.DataFrame:
+			if f.StreamEnded() {
+				err = s.respond(fr, enc, &headers, f.StreamID)
+			}
+		case *http2.GoAwayFrame:
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// respond writes the response headers, the frames and the trailers once the
+// client has finished sending its request on a stream
+func (s *MockGrpcServer) respond(fr *http2.Framer, enc *hpack.Encoder, headers *bytes.Buffer, streamID uint32) error {
+	headers.Reset()
+	enc.WriteField(hpack.HeaderField{Name: ":status", Value: "200"})
+	enc.WriteField(hpack.HeaderField{Name: "content-type", Value: "application/grpc"})
+	err := fr.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      streamID,
+		BlockFragment: headers.Bytes(),
+		EndHeaders:    true,
+	})
+	if err != nil {
+		return err
+	}
+	for _, frame := range s.Frames {
+		// the default maximum HTTP/2 frame size is 16KB
+		for len(frame) > 0 {
+			n := len(frame)
+			if n > 16384 {
+				n = 16384
+			}
+			if err := fr.WriteData(streamID, false, frame[:n]); err != nil {
+				return err
+			}
+			frame = frame[n:]
+		}
+	}
+	headers.Reset()
+	enc.WriteField(hpack.HeaderField{Name: "grpc-status", Value: "0"})
+	return fr.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      streamID,
+		BlockFragment: headers.Bytes(),
+		EndHeaders:    true,
+		EndStream:     true,
+	})
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// histogramWidth is the length of the longest bar WriteHistogram draws
+const histogramWidth = 40
+
+// sizeBuckets are the SizeHistogram buckets in order, each holding sizes
+// below max
+var sizeBuckets = []struct {
+	label string
+	max   int
+}{
+	{"<64B", 64},
+	{"64-256B", 256},
+	{"256B-1KB", 1 << 10},
+	{"1KB-4KB", 4 << 10},
+	{"4KB-64KB", 64 << 10},
+	{">64KB", 0},
+}
+
+func newSizeHistogram() map[string]int {
+	hist := make(map[string]int, len(sizeBuckets))
+	for _, b := range sizeBuckets {
+		hist[b.label] = 0
+	}
+	return hist
+}
+
+func sizeBucket(size int) string {
+	for _, b := range sizeBuckets {
+		if size < b.max {
+			return b.label
+		}
+	}
+	return sizeBuckets[len(sizeBuckets)-1].label
+}
+
+// SizeHistogram counts messages by their encoded size. Every bucket is
+// present in the result, even if empty.
+func SizeHistogram(msgs []*Message) map[string]int {
+	hist := newSizeHistogram()
+	for _, m := range msgs {
+		hist[sizeBucket(EncodedSize(m))]++
+	}
+	return hist
+}
+
+// FieldSizeHistogram is like SizeHistogram for the lengths of the
+// length-delimited values of a top-level field
+func FieldSizeHistogram(msgs []*Message, id uint64) map[string]int {
+	hist := newSizeHistogram()
+	for _, m := range msgs {
+		if m == nil {
+			continue
+		}
+		for _, f := range (*m)[id] {
+			switch {
+			case f.string != nil:
+				hist[sizeBucket(len(*f.string))]++
+			case f.bytes != nil:
+				hist[sizeBucket(len(*f.bytes))]++
+			case f.message != nil && f.wireType == LengthDelim:
+				hist[sizeBucket(EncodedSize(f.message))]++
+			}
+		}
+	}
+	return hist
+}
+
+// WriteHistogram draws a histogram from SizeHistogram as a bar chart, one
+// line per bucket, scaled so the largest bucket has the longest bar
+func WriteHistogram(w io.Writer, hist map[string]int) error {
+	return WriteHistogramWithOptions(w, hist, RenderOptions{})
+}
+
+// WriteHistogramWithOptions is like WriteHistogram but labels the buckets
+// with human-readable sizes if opts.HumanReadable is set
+func WriteHistogramWithOptions(w io.Writer, hist map[string]int, opts RenderOptions) error {
+	out := &output{w: w}
+	most := 0
+	labels := make([]string, len(sizeBuckets))
+	width := 0
+	for i, b := range sizeBuckets {
+		if hist[b.label] > most {
+			most = hist[b.label]
+		}
+		labels[i] = b.label
+		if opts.HumanReadable {
+			labels[i] = humanBucketLabel(i, opts.SI)
+		}
+		if len(labels[i]) > width {
+			width = len(labels[i])
+		}
+	}
+	for i, b := range sizeBuckets {
+		count := hist[b.label]
+		bar := 0
+		if most > 0 {
+			bar = (count*histogramWidth + most - 1) / most
+		}
+		fmt.Fprintf(out, "%-*s | %-*s %d\n", width, labels[i], histogramWidth, strings.Repeat("#", bar), count)
+	}
+	return out.err
+}
+
+// humanBucketLabel labels the ith of sizeBuckets by its bounds, as in
+// "1.0 KiB-4.0 KiB"
+func humanBucketLabel(i int, si bool) string {
+	size := func(n int) string { return byteCount{n: n, human: true, si: si}.String() }
+	switch i {
+	case 0:
+		return "<" + size(sizeBuckets[0].max)
+	case len(sizeBuckets) - 1:
+		return ">" + size(sizeBuckets[i-1].max)
+	default:
+		return size(sizeBuckets[i-1].max) + "-" + size(sizeBuckets[i].max)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	http2HeaderLen  = 9
+	http2DataFrame  = 0x0
+	http2FlagPadded = 0x8
+)
+
+func ParseGrpcHTTP2(frame []byte, opts ...ParseOption) ([]*Message, error) {
+	if len(frame) < http2HeaderLen {
+		return nil, fmt.Errorf("Missing HTTP/2 frame header, only %d bytes available", len(frame))
+	}
+	length := int(frame[0])<<16 | int(frame[1])<<8 | int(frame[2])
+	typ := frame[3]
+	flags := frame[4]
+	streamID := binary.BigEndian.Uint32(frame[5:9]) & 0x7fffffff
+	if typ != http2DataFrame {
+		return nil, fmt.Errorf("Expected HTTP/2 DATA frame (type 0x0) but found type 0x%x", typ)
+	}
+	if streamID == 0 {
+		return nil, fmt.Errorf("HTTP/2 DATA frame must not use stream 0")
+	}
+	payload := frame[http2HeaderLen:]
+	if len(payload) < length {
+		return nil, fmt.Errorf("Incomplete HTTP/2 frame, wanted %d bytes but only found %d", length, len(payload))
+	}
+	payload = payload[:length]
+	if flags&http2FlagPadded != 0 {
+		if len(payload) == 0 {
+			return nil, fmt.Errorf("Missing pad length in padded HTTP/2 DATA frame")
+		}
+		padLen := int(payload[0])
+		if padLen >= len(payload) {
+			return nil, fmt.Errorf("HTTP/2 padding of %d bytes exceeds frame payload of %d bytes", padLen, len(payload))
+		}
+		payload = payload[1 : len(payload)-padLen]
+	}
+	return ParseGrpcStream(payload, opts...)
+}
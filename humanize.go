@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+var (
+	binaryUnits = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+	siUnits     = []string{"B", "KB", "MB", "GB", "TB", "PB", "EB"}
+)
+
+// HumanizeBytes formats a byte count with binary prefixes, e.g. "1.2 MiB"
+func HumanizeBytes(n int) string {
+	return humanize(n, 1024, binaryUnits)
+}
+
+// HumanizeBytesSI formats a byte count with decimal prefixes, e.g. "1.2 MB"
+func HumanizeBytesSI(n int) string {
+	return humanize(n, 1000, siUnits)
+}
+
+func humanize(n int, base float64, units []string) string {
+	if n < 0 {
+		return "-" + humanize(-n, base, units)
+	}
+	if float64(n) < base {
+		return fmt.Sprintf("%d B", n)
+	}
+	v := float64(n) / base
+	unit := 1
+	// move up a unit when rounding would print e.g. "1024 KiB"
+	for v >= 9.95 && float64(int(v+0.5)) >= base && unit < len(units)-1 {
+		v /= base
+		unit++
+	}
+	if v < 9.95 {
+		return fmt.Sprintf("%.1f %s", v, units[unit])
+	}
+	return fmt.Sprintf("%.0f %s", v, units[unit])
+}
+
+// byteCount formats as a plain number unless human-readable sizes were
+// requested, so it can stand in for an int in user-supplied formats
+type byteCount struct {
+	n     int
+	human bool
+	si    bool
+}
+
+// String gives the count with its unit, such as "1234 bytes" or "1.2 KiB"
+func (c byteCount) String() string {
+	if !c.human {
+		return fmt.Sprintf("%d bytes", c.n)
+	}
+	return fmt.Sprint(c)
+}
+
+func (c byteCount) Format(f fmt.State, verb rune) {
+	switch {
+	case c.human && c.si:
+		io.WriteString(f, HumanizeBytesSI(c.n))
+	case c.human:
+		io.WriteString(f, HumanizeBytes(c.n))
+	default:
+		fmt.Fprintf(f, fmt.FormatString(f, verb), c.n)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// IndexProto records the offset of each field's value in data, just past
+// its tag, without decoding any values. If data is corrupt, the offsets
+// found before the corruption are returned along with the error.
+func IndexProto(data []byte) (map[uint64][]int, error) {
+	index := map[uint64][]int{}
+	for pos := 0; pos < len(data); {
+		tag, n, err := ParseTag(data[pos:])
+		if err != nil {
+			return index, fmt.Errorf("Offset %d: %v", pos, err)
+		}
+		pos += n
+		m := protowire.ConsumeFieldValue(protowire.Number(tag.fieldID), protowire.Type(tag.typ), data[pos:])
+		if m < 0 {
+			return index, fmt.Errorf("Offset %d: %v", pos, protowire.ParseError(m))
+		}
+		index[tag.fieldID] = append(index[tag.fieldID], pos)
+		pos += m
+	}
+	return index, nil
+}
+
+// ParseFieldAt decodes the value at an offset found by IndexProto, giving
+// the same Field that ParseProto would
+func ParseFieldAt(data []byte, offset int, wireType uint64) (Field, error) {
+	if offset < 0 || offset > len(data) {
+		return Field{}, fmt.Errorf("Offset %d is outside of the %d byte message", offset, len(data))
+	}
+	data = data[offset:]
+	switch wireType {
+	case Varint:
+		x, _, err := consumeVarint(data)
+		if err != nil {
+			return Field{}, err
+		}
+		return Field{numeric: &x, wireType: Varint}, nil
+	case B32:
+		v, n := protowire.ConsumeFixed32(data)
+		if n < 0 {
+			return Field{}, protowire.ParseError(n)
+		}
+		x := uint64(v)
+		return Field{numeric: &x, wireType: B32}, nil
+	case B64:
+		x, n := protowire.ConsumeFixed64(data)
+		if n < 0 {
+			return Field{}, protowire.ParseError(n)
+		}
+		return Field{numeric: &x, wireType: B64}, nil
+	case LengthDelim:
+		x, n, err := consumeVarint(data)
+		if err != nil {
+			return Field{}, err
+		}
+		if err := checkLengthDelim(x); err != nil {
+			return Field{}, err
+		}
+		if uint64(len(data)-n) < x {
+			return Field{}, fmt.Errorf("Not enough bytes for length delimited field, wanted %d but only found %d", x, len(data)-n)
+		}
+		return parseLengthDelim(data[n:n+int(x)], DefaultOptions, 0)
+	case SGroup:
+		id, err := groupEndID(data)
+		if err != nil {
+			return Field{}, err
+		}
+		msg, _, err := parseGroup(data, DefaultOptions, id)
+		if err != nil {
+			return Field{}, err
+		}
+		return Field{message: msg, wireType: SGroup}, nil
+	default:
+		return Field{}, fmt.Errorf("Cannot parse a field with wire type %d", wireType)
+	}
+}
+
+// groupEndID finds the field ID of the end group tag that closes a group
+// starting at data, since the start tag isn't available
+func groupEndID(data []byte) (uint64, error) {
+	for pos := 0; pos < len(data); {
+		tag, n, err := ParseTag(data[pos:])
+		if err != nil {
+			return 0, err
+		}
+		pos += n
+		if tag.typ == EGroup {
+			return tag.fieldID, nil
+		}
+		m := protowire.ConsumeFieldValue(protowire.Number(tag.fieldID), protowire.Type(tag.typ), data[pos:])
+		if m < 0 {
+			return 0, protowire.ParseError(m)
+		}
+		pos += m
+	}
+	return 0, fmt.Errorf("Unclosed group")
+}
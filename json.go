@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+func RenderJSON(m *Message) string {
+	return RenderWithOptions(m, RenderOptions{
+		Sorted:               true,
+		BytesFormat:          BytesBase64,
+		JSONConformantFloats: true,
+	})
+}
+
+// RenderNDJSON writes each message as JSON on its own line, with one Write
+// call per line, stopping at the first write error
+func RenderNDJSON(msgs []*Message, w io.Writer) error {
+	for _, m := range msgs {
+		if _, err := io.WriteString(w, RenderJSON(m)+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m Message) MarshalJSON() ([]byte, error) {
+	return []byte(RenderJSON(&m)), nil
+}
+
+func (m *Message) UnmarshalJSON(data []byte) error {
+	parsed, err := ParseProtoJSON(data)
+	if err != nil {
+		return err
+	}
+	*m = *parsed
+	return nil
+}
+
+func ParseProtoJSON(data []byte) (*Message, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("Invalid JSON: %v", err)
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("Invalid JSON: unexpected data after top-level value")
+	}
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Expected a JSON object at top level, found %T", v)
+	}
+	return jsonToMessage(obj)
+}
+
+func MessageFromMap(m map[string]interface{}) (*Message, error) {
+	return jsonToMessage(m)
+}
+
+func jsonToMessage(obj map[string]interface{}) (*Message, error) {
+	msg := NewMessage()
+	for key, value := range obj {
+		id, err := strconv.ParseUint(key, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid field ID %q, JSON keys must be numeric field IDs", key)
+		}
+		if values, ok := value.([]interface{}); ok {
+			for _, v := range values {
+				if _, nested := v.([]interface{}); nested {
+					return nil, fmt.Errorf("Nested arrays are not allowed for field %d", id)
+				}
+				if err := addJSONValue(msg, id, v); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		if err := addJSONValue(msg, id, value); err != nil {
+			return nil, err
+		}
+	}
+	return &msg, nil
+}
+
+func addJSONValue(msg Message, id uint64, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		// proto3 JSON uses null for a field with its default value
+	case string:
+		addField(msg, id, Field{
+			string:   &v,
+			wireType: LengthDelim,
+		})
+	case bool:
+		var x uint64
+		if v {
+			x = 1
+		}
+		addField(msg, id, Field{
+			numeric: &x,
+		})
+	case json.Number:
+		x, err := parseJSONNumber(v)
+		if err != nil {
+			return fmt.Errorf("Invalid number for field %d: %v", id, err)
+		}
+		addField(msg, id, Field{
+			numeric: &x,
+		})
+	case float64:
+		x, err := parseJSONFloat(v)
+		if err != nil {
+			return fmt.Errorf("Invalid number for field %d: %v", id, err)
+		}
+		addField(msg, id, Field{
+			numeric: &x,
+		})
+	case map[string]interface{}:
+		subMsg, err := jsonToMessage(v)
+		if err != nil {
+			return err
+		}
+		addField(msg, id, Field{
+			message:  subMsg,
+			wireType: LengthDelim,
+		})
+	default:
+		return fmt.Errorf("Unsupported JSON value %T for field %d", value, id)
+	}
+	return nil
+}
+
+func parseJSONNumber(n json.Number) (uint64, error) {
+	if x, err := strconv.ParseUint(n.String(), 10, 64); err == nil {
+		return x, nil
+	}
+	if x, err := strconv.ParseInt(n.String(), 10, 64); err == nil {
+		return uint64(x), nil
+	}
+	return 0, fmt.Errorf("%s is not an integer", n)
+}
+
+func parseJSONFloat(v float64) (uint64, error) {
+	if v != math.Trunc(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("%g is not an integer", v)
+	}
+	if v < 0 {
+		if v < math.MinInt64 {
+			return 0, fmt.Errorf("%g is out of range", v)
+		}
+		return uint64(int64(v)), nil
+	}
+	if v >= math.MaxUint64 {
+		return 0, fmt.Errorf("%g is out of range", v)
+	}
+	return uint64(v), nil
+}
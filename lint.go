@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type Severity int
+
+const (
+	SeverityWarning Severity = iota
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+type LintIssue struct {
+	FieldID  uint64
+	Severity Severity
+	Message  string
+}
+
+func (i LintIssue) String() string {
+	return fmt.Sprintf("%s: field %d: %s", i.Severity, i.FieldID, i.Message)
+}
+
+const (
+	maxFieldID          = 1<<29 - 1
+	reservedFieldIDLow  = 19000
+	reservedFieldIDHigh = 19999
+	// minBase64Length keeps short words from being reported as base64
+	minBase64Length = 16
+)
+
+// Lint reports likely mistakes in how a message was encoded, checking
+// sub-messages recursively
+func Lint(m *Message) []LintIssue {
+	issues := []LintIssue{}
+	if m == nil {
+		return issues
+	}
+	for _, id := range m.FieldIDs() {
+		fields := (*m)[id]
+		switch {
+		case id == 0 || id > maxFieldID:
+			issues = append(issues, LintIssue{id, SeverityError, fmt.Sprintf("field IDs must be between 1 and %d", maxFieldID)})
+		case id >= reservedFieldIDLow && id <= reservedFieldIDHigh:
+			issues = append(issues, LintIssue{id, SeverityWarning, fmt.Sprintf("field IDs %d-%d are reserved for the protobuf implementation", reservedFieldIDLow, reservedFieldIDHigh)})
+		}
+		if allZero(fields) {
+			issues = append(issues, LintIssue{id, SeverityWarning, "value is always 0, the proto3 default, which need not be sent"})
+		}
+		for _, f := range fields {
+			var content string
+			switch {
+			case f.string != nil:
+				content = *f.string
+			case f.bytes != nil:
+				content = string(*f.bytes)
+			case f.message != nil:
+				if len(*f.message) == 1 {
+					issues = append(issues, LintIssue{id, SeverityWarning, "sub-message has a single field, it may be an unnecessary wrapper"})
+				}
+				issues = append(issues, Lint(f.message)...)
+				continue
+			default:
+				continue
+			}
+			trimmed := strings.TrimSpace(content)
+			if (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) && json.Valid([]byte(trimmed)) {
+				issues = append(issues, LintIssue{id, SeverityWarning, "value looks like JSON, it may be better as a sub-message"})
+			} else if looksLikeBase64(content) {
+				issues = append(issues, LintIssue{id, SeverityWarning, "value looks like base64, it may be better sent as decoded bytes"})
+			}
+		}
+	}
+	return issues
+}
+
+func allZero(fields []Field) bool {
+	for _, f := range fields {
+		if f.numeric == nil || *f.numeric != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func looksLikeBase64(s string) bool {
+	if len(s) < minBase64Length || len(s)%4 != 0 {
+		return false
+	}
+	_, err := base64.StdEncoding.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+type LoggingOptions struct {
+	// ParseOptions are used to parse each logged request and response
+	ParseOptions ParseOptions
+}
+
+// FileLogger is a pair of gRPC client interceptors that append one NDJSON
+// line per call to a file. It is safe for concurrent calls.
+type FileLogger struct {
+	opts LoggingOptions
+	mu   sync.Mutex
+	f    *os.File
+}
+
+// logEntry is one line of a FileLogger log. Unary calls fill in Request
+// and Response, streaming calls Requests and Responses.
+type logEntry struct {
+	Method     string            `json:"method"`
+	Time       time.Time         `json:"time"`
+	Request    json.RawMessage   `json:"request,omitempty"`
+	Response   json.RawMessage   `json:"response,omitempty"`
+	Requests   []json.RawMessage `json:"requests,omitempty"`
+	Responses  []json.RawMessage `json:"responses,omitempty"`
+	StatusCode int               `json:"status_code"`
+	Error      string            `json:"error,omitempty"`
+	LatencyMs  float64           `json:"latency_ms"`
+}
+
+func NewFileLogger(path string, opts LoggingOptions) (*FileLogger, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileLogger{opts: opts, f: f}, nil
+}
+
+func (l *FileLogger) Close() error {
+	return l.f.Close()
+}
+
+func (l *FileLogger) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		entry := l.newEntry(method, start, err)
+		entry.Request = l.render(req)
+		if err == nil {
+			entry.Response = l.render(reply)
+		}
+		l.write(entry)
+		return err
+	}
+}
+
+func (l *FileLogger) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			l.write(l.newEntry(method, start, err))
+			return nil, err
+		}
+		s := &loggedStream{ClientStream: stream, logger: l, desc: desc, method: method, start: start}
+		go func() {
+			// grpc cancels the stream's context once the call is over, but
+			// only a done caller context means the call was cancelled or
+			// timed out rather than finished by RecvMsg
+			<-stream.Context().Done()
+			if err := ctx.Err(); err != nil {
+				s.finish(status.FromContextError(err).Err())
+			}
+		}()
+		return s, nil
+	}
+}
+
+// loggedStream records the messages of a stream, logging them once the
+// call is over: when the server ends it, after the single response of a
+// client-streaming call, or when the caller's context is done
+type loggedStream struct {
+	grpc.ClientStream
+	logger    *FileLogger
+	desc      *grpc.StreamDesc
+	method    string
+	start     time.Time
+	mu        sync.Mutex
+	requests  []json.RawMessage
+	responses []json.RawMessage
+	done      bool
+}
+
+func (s *loggedStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.mu.Lock()
+		s.requests = append(s.requests, s.logger.render(m))
+		s.mu.Unlock()
+	}
+	return err
+}
+
+func (s *loggedStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == nil {
+		s.mu.Lock()
+		s.responses = append(s.responses, s.logger.render(m))
+		s.mu.Unlock()
+		if !s.desc.ServerStreams {
+			s.finish(nil)
+		}
+		return nil
+	}
+	if err == io.EOF {
+		s.finish(nil)
+	} else {
+		s.finish(err)
+	}
+	return err
+}
+
+// finish writes the stream's log entry, unless it has already been written
+func (s *loggedStream) finish(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done {
+		return
+	}
+	s.done = true
+	entry := s.logger.newEntry(s.method, s.start, err)
+	entry.Requests, entry.Responses = s.requests, s.responses
+	s.logger.write(entry)
+}
+
+func (l *FileLogger) newEntry(method string, start time.Time, err error) logEntry {
+	entry := logEntry{
+		Method:     method,
+		Time:       start.UTC(),
+		StatusCode: int(status.Code(err)),
+		LatencyMs:  float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	return entry
+}
+
+// render encodes a request or response and renders it as JSON, or null if
+// it isn't a proto message or raw bytes that parse as one
+func (l *FileLogger) render(m interface{}) json.RawMessage {
+	var data []byte
+	switch m := m.(type) {
+	case proto.Message:
+		var err error
+		if data, err = proto.Marshal(m); err != nil {
+			return json.RawMessage("null")
+		}
+	case []byte:
+		data = m
+	case *[]byte:
+		data = *m
+	default:
+		return json.RawMessage("null")
+	}
+	msg, _, err := ParseProtoWithOptions(data, l.opts.ParseOptions)
+	if err != nil {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(RenderJSON(msg))
+}
+
+func (l *FileLogger) write(entry logEntry) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.f.Write(append(line, '\n'))
+}
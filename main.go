@@ -1,19 +1,32 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"flag"
 	"fmt"
-	"io/ioutil"
+	"log/slog"
+	"math"
+	"net/http"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"unicode/utf8"
 
-	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protowire"
 )
 
 const (
 	Varint      = 0
 	B64         = 1
 	LengthDelim = 2
+	SGroup      = 3
+	EGroup      = 4
 	B32         = 5
 )
 
@@ -23,17 +36,132 @@ type (
 		string  *string
 		message *Message
 		bytes   *[]byte
+		// wireType records how the field was encoded; sub-messages
+		// parsed from a group are kept distinct from length-delimited ones
+		wireType uint64
+		// span is the value's position in the input, recorded only by
+		// ParseProtoAnnotated
+		span *byteRange
+		// content holds the bytes a length-delimited sub-message was
+		// parsed from, in case a schema says it is a string or bytes
+		content []byte
 	}
 
-	Message = map[uint64][]Field
+	byteRange struct {
+		start, end int
+	}
+
+	Message map[uint64][]Field
 
 	Tag struct {
 		fieldID uint64
 		typ     uint64
 	}
+
+	ParseOptions struct {
+		// ForceBytes and ForceString override the sub-message heuristic
+		// for length-delimited fields of the top-level message
+		ForceBytes  []uint64
+		ForceString []uint64
+		// ForceMessage requires the listed length-delimited fields to
+		// parse as sub-messages; ForceMessageDeep applies at every level
+		ForceMessage     []uint64
+		ForceMessageDeep []uint64
+		// ByteBudget, when set, caps the bytes spent on top-level field
+		// values (tags excluded); parsing stops with ErrBudgetExceeded
+		// and returns the fields read so far
+		ByteBudget *int
+		// RetainRaw keeps the undecoded payload of each gRPC frame in
+		// GrpcFrame.RawPayload
+		RetainRaw bool
+		// MaxFieldID, when non-zero, rejects field IDs above it in Strict
+		// mode and otherwise skips those fields
+		MaxFieldID uint64
+		Strict     bool
+		// MaxDepth limits sub-message nesting, MaxSize the bytes in a
+		// message and MaxFields the fields across every level; zero means
+		// unlimited. Content nested past MaxDepth is an error if it parses
+		// as a message, and is otherwise kept as a string or bytes.
+		MaxDepth  int
+		MaxSize   int
+		MaxFields int
+
+		// MaxFieldLengths caps the content length of length-delimited
+		// fields by ID; longer values are an error in Strict mode and are
+		// otherwise truncated with a warning
+		MaxFieldLengths map[uint64]int
+		// MaxFieldSizes caps the length of length-delimited fields by ID
+		// like MaxFieldLengths, but longer values are a FieldTooLargeError
+		// in Strict mode and are otherwise skipped with a warning; zero
+		// means unlimited
+		MaxFieldSizes map[uint64]int
+		// Warn, if set, is called for each problem lenient parsing
+		// recovers from
+		Warn func(error)
+		// PackedFields decodes the listed length-delimited fields of the
+		// top-level message as packed repeated varints
+		PackedFields []uint64
+		// NoRecurse stores every length-delimited field not listed in
+		// ForceMessage as bytes; StringsOnly still detects UTF-8 strings
+		// but never tries parsing sub-messages
+		NoRecurse   bool
+		StringsOnly bool
+		// Schema lists the known fields of the top-level message; with
+		// ParseProtoMessage, fields outside it are kept as UnknownFields
+		Schema []FieldSpec
+		// TrackPresence fills in ParsedMessage.PresentFields
+		TrackPresence bool
+		// Parallelism is the number of goroutines ParseProtoAll uses,
+		// runtime.NumCPU() if zero
+		Parallelism int
+		// WireTypeHandlers replace the built-in decoding of a wire type,
+		// and allow wire types the parser would otherwise reject
+		WireTypeHandlers map[uint64]WireTypeHandler
+		// OneofGroups lists sets of top-level field IDs of which at most
+		// one may be present; a second is an error in Strict mode and
+		// otherwise a warning
+		OneofGroups [][]uint64
+		// RequiredFields lists top-level field IDs that must be present,
+		// each missing one giving a MissingRequiredFieldError. Strict mode
+		// reports only the first; otherwise they are all joined and the
+		// message is returned too.
+		RequiredFields []uint64
+		// DepthContext prefixes errors in sub-messages with the path of
+		// field IDs leading to them, as in "at field 1.3.2: ..."
+		DepthContext bool
+
+		depth      int
+		fieldCount *int
+		annotate   bool
+		// offset is the position of the data being parsed in the input
+		offset        int
+		unknownFields *[]UnknownField
+		// path is the field IDs leading to the data, kept for DepthContext
+		path []uint64
+	}
+
+	// WireTypeHandler decodes a field's value from the bytes after its tag,
+	// returning the field and the number of bytes consumed
+	WireTypeHandler func(data []byte) (Field, int, error)
+
+	GrpcFrame struct {
+		Compressed bool
+		Size       uint32
+		// Message is nil for compressed frames, which cannot be decoded
+		Message    *Message
+		RawPayload []byte
+	}
 )
 
-func addField(m Message, id uint64, field Field) {
+var ErrBudgetExceeded = errors.New("Byte budget exceeded")
+
+func NewMessage() Message {
+	return make(Message)
+}
+
+// Add appends a value for a field. Like any map, a Message must not be
+// modified concurrently; use SafeMessage for that.
+func (m Message) Add(id uint64, field Field) {
 	fields, ok := m[id]
 	if ok {
 		m[id] = append(fields, field)
@@ -42,88 +170,705 @@ func addField(m Message, id uint64, field Field) {
 	}
 }
 
-func ParseGrpc(data []byte) (*Message, int, error) {
+func (m Message) Fields(id uint64) []Field {
+	return m[id]
+}
+
+func (m Message) FieldIDs() []uint64 {
+	ids := make([]uint64, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func (f Field) Bool() (bool, bool) {
+	if f.numeric == nil || f.wireType != Varint {
+		return false, false
+	}
+	return *f.numeric != 0, true
+}
+
+// BytesCopy returns the content of a bytes or string field as a slice the
+// caller owns, or nil for other fields
+func (f Field) BytesCopy() []byte {
+	switch {
+	case f.bytes != nil:
+		return append([]byte{}, *f.bytes...)
+	case f.string != nil:
+		return []byte(*f.string)
+	}
+	return nil
+}
+
+// BytesUnsafe returns the content of a bytes field without copying. A
+// parsed field shares memory with the input, so the result changes if the
+// input does; strings are always copied.
+func (f Field) BytesUnsafe() []byte {
+	if f.bytes != nil {
+		return *f.bytes
+	}
+	return f.BytesCopy()
+}
+
+// messageBytes returns the bytes a sub-message was parsed from, or its
+// encoding if it wasn't parsed from the wire
+func (f Field) messageBytes() ([]byte, error) {
+	if f.content != nil {
+		return f.content, nil
+	}
+	return Encode(f.message)
+}
+
+// ByteRange returns the [start, end) offsets of the field's value in the
+// input, if the message came from ParseProtoAnnotated
+func (f Field) ByteRange() (int, int, bool) {
+	if f.span == nil {
+		return 0, 0, false
+	}
+	return f.span.start, f.span.end, true
+}
+
+func addField(m Message, id uint64, field Field) {
+	m.Add(id, field)
+}
+
+func ParseGrpc(data []byte, opts ...ParseOption) (*Message, int, error) {
+	return ParseGrpcWithOptions(data, applyOptions(opts))
+}
+
+// ParseGrpcWithOptions returns the bytes consumed by the whole frame, which
+// is always the 5-byte header plus the declared size, even when the payload
+// is only partially parsed
+func ParseGrpcWithOptions(data []byte, opts ParseOptions) (*Message, int, error) {
 	if len(data) < 5 {
 		return nil, 0, fmt.Errorf("Missing gRPC frame size, only %d bytes available", len(data))
 	}
-	size := 0
-	for i := 0; i < 5; i++ {
-		size = size << 8
-		size += int(data[i])
+	size, err := parseGrpcHeader(data[:5])
+	if err != nil {
+		return nil, 0, err
 	}
 	data = data[5:]
-	if len(data) < int(size) {
+	if uint64(len(data)) < uint64(size) {
 		return nil, 0, fmt.Errorf("Incomplete gRPC frame, wanted %d bytes but only found %d", size, len(data))
 	}
-	msg, n, err := ParseProto(data[:size])
-	return msg, n + 5, err
+	msg, _, err := ParseProtoWithOptions(data[:size], opts.at(5))
+	return msg, 5 + int(size), err
+}
+
+func ParseGrpcFrame(data []byte, opts ...ParseOption) (*GrpcFrame, int, error) {
+	return ParseGrpcFrameWithOptions(data, applyOptions(opts))
+}
+
+func ParseGrpcFrameWithOptions(data []byte, opts ParseOptions) (*GrpcFrame, int, error) {
+	if len(data) < 5 {
+		return nil, 0, fmt.Errorf("Missing gRPC frame size, only %d bytes available", len(data))
+	}
+	compressed, size, err := readGrpcHeader(data[:5])
+	if err != nil {
+		return nil, 0, err
+	}
+	data = data[5:]
+	if uint64(len(data)) < uint64(size) {
+		return nil, 0, fmt.Errorf("Incomplete gRPC frame, wanted %d bytes but only found %d", size, len(data))
+	}
+	frame := &GrpcFrame{
+		Compressed: compressed,
+		Size:       size,
+	}
+	if opts.RetainRaw {
+		frame.RawPayload = data[:size]
+	}
+	if !compressed {
+		frame.Message, _, err = ParseProtoWithOptions(data[:size], opts.at(5))
+	}
+	return frame, 5 + int(size), err
+}
+
+func parseGrpcHeader(header []byte) (uint32, error) {
+	compressed, size, err := readGrpcHeader(header)
+	if err != nil {
+		return 0, err
+	}
+	if compressed {
+		return 0, fmt.Errorf("Compressed gRPC frames are not supported")
+	}
+	return size, nil
+}
+
+func readGrpcHeader(header []byte) (bool, uint32, error) {
+	compressed := header[0]
+	if compressed > 1 {
+		return false, 0, fmt.Errorf("Invalid gRPC compression flag: %d", compressed)
+	}
+	return compressed == 1, binary.BigEndian.Uint32(header[1:5]), nil
+}
+
+func ParseGrpcStream(data []byte, opts ...ParseOption) ([]*Message, error) {
+	return ParseGrpcStreamWithOptions(data, applyOptions(opts))
+}
+
+func ParseGrpcStreamWithOptions(data []byte, opts ParseOptions) ([]*Message, error) {
+	msgs := []*Message{}
+	for offset := 0; offset < len(data); {
+		msg, n, err := ParseGrpcWithOptions(data[offset:], opts.at(offset))
+		if err != nil {
+			return nil, fmt.Errorf("Frame %d: %v", len(msgs)+1, err)
+		}
+		msgs = append(msgs, msg)
+		offset += n
+	}
+	return msgs, nil
+}
+
+func SkipGrpcFrames(data []byte, n int) ([]byte, int, error) {
+	skipped := 0
+	for i := 0; i < n; i++ {
+		if len(data) < 5 {
+			return nil, skipped, fmt.Errorf("Cannot skip %d frames, only %d available", n, i)
+		}
+		size, err := parseGrpcHeader(data[:5])
+		if err != nil {
+			return nil, skipped, err
+		}
+		if uint64(len(data)-5) < uint64(size) {
+			return nil, skipped, fmt.Errorf("Incomplete gRPC frame, wanted %d bytes but only found %d", size, len(data)-5)
+		}
+		data = data[5+int(size):]
+		skipped += 5 + int(size)
+	}
+	return data, skipped, nil
+}
+
+func ParseProto(data []byte, opts ...ParseOption) (*Message, int, error) {
+	return ParseProtoWithOptions(data, applyOptions(opts))
+}
+
+// ParseProtoAnnotated is like ParseProto but also records where each value
+// lies in data, for Field.ByteRange and RenderOptions.ShowByteRanges
+func ParseProtoAnnotated(data []byte, opts ...ParseOption) (*Message, error) {
+	o := applyOptions(opts)
+	o.annotate = true
+	msg, _, err := ParseProtoWithOptions(data, o)
+	return msg, err
+}
+
+func ParseProtoWithOptions(data []byte, opts ParseOptions) (*Message, int, error) {
+	if opts.MaxSize > 0 && len(data) > opts.MaxSize {
+		return nil, 0, &limitError{limit: "size", max: opts.MaxSize}
+	}
+	if opts.MaxFields > 0 && opts.fieldCount == nil {
+		opts.fieldCount = new(int)
+	}
+	msg, n, err := parseMessage(data, opts, 0, false)
+	if err == nil {
+		err = opts.checkRequired(msg)
+	}
+	return msg, n, opts.pathError(err)
+}
+
+func (opts ParseOptions) checkRequired(msg *Message) error {
+	var errs []error
+	for _, id := range opts.RequiredFields {
+		if _, ok := (*msg)[id]; ok {
+			continue
+		}
+		if opts.Strict {
+			return &MissingRequiredFieldError{ID: id}
+		}
+		errs = append(errs, &MissingRequiredFieldError{ID: id})
+	}
+	return errors.Join(errs...)
+}
+
+func parseGroup(data []byte, opts ParseOptions, id uint64) (*Message, int, error) {
+	opts = opts.child(id)
+	msg, n, err := parseMessage(data, opts, id, true)
+	return msg, n, opts.pathError(err)
 }
 
-func ParseProto(data []byte) (*Message, int, error) {
+func parseMessage(data []byte, opts ParseOptions, groupID uint64, inGroup bool) (*Message, int, error) {
 	pos := 0
-	msg := make(Message)
+	spent := 0
+	msg := NewMessage()
+	// oneofsSeen maps each index of OneofGroups to the first field seen
+	// from that group
+	var oneofsSeen map[int]uint64
+	if len(opts.OneofGroups) > 0 {
+		oneofsSeen = map[int]uint64{}
+	}
+	if opts.MaxDepth > 0 && opts.depth > opts.MaxDepth && len(data) > 0 {
+		return nil, 0, opts.depthLimitError(data, inGroup)
+	}
 	for pos < len(data) {
-		tag, n, err := ParseTag(data[pos:])
+		start := pos
+		tag, n, err := opts.parseTag(data[pos:])
 		if err != nil {
 			return nil, 0, err
 		}
 		pos += n
+		if opts.fieldCount != nil && tag.typ != EGroup {
+			if *opts.fieldCount++; *opts.fieldCount > opts.MaxFields {
+				return nil, 0, &limitError{limit: "number of fields", max: opts.MaxFields}
+			}
+		}
+		if opts.MaxFieldID > 0 && tag.fieldID > opts.MaxFieldID && tag.typ != EGroup {
+			if opts.Strict {
+				return nil, 0, fmt.Errorf("Field %d is above the maximum field ID %d", tag.fieldID, opts.MaxFieldID)
+			}
+			n := protowire.ConsumeFieldValue(protowire.Number(tag.fieldID), protowire.Type(tag.typ), data[pos:])
+			if n < 0 {
+				return nil, 0, protowire.ParseError(n)
+			}
+			pos += n
+			continue
+		}
+		if other, ok := oneofConflict(opts.OneofGroups, oneofsSeen, tag); ok {
+			err := fmt.Errorf("Fields %d and %d are both set but belong to the same oneof", other, tag.fieldID)
+			if opts.Strict {
+				return nil, 0, err
+			}
+			opts.warn(err)
+		}
+		if opts.unknownFields != nil && tag.typ != EGroup && !inSchema(opts.Schema, tag) {
+			n := protowire.ConsumeFieldValue(protowire.Number(tag.fieldID), protowire.Type(tag.typ), data[pos:])
+			if n < 0 {
+				return nil, 0, protowire.ParseError(n)
+			}
+			*opts.unknownFields = append(*opts.unknownFields, UnknownField{
+				ID:       tag.fieldID,
+				WireType: tag.typ,
+				Data:     data[pos : pos+n],
+			})
+			pos += n
+			continue
+		}
+		if handler, ok := opts.WireTypeHandlers[tag.typ]; ok {
+			field, n, err := handler(data[pos:])
+			if err != nil {
+				return nil, 0, err
+			}
+			if n < 0 || n > len(data)-pos {
+				return nil, 0, fmt.Errorf("Wire type %d handler consumed %d bytes but only %d were available", tag.typ, n, len(data)-pos)
+			}
+			if opts.overBudget(spent, n) {
+				return &msg, start, ErrBudgetExceeded
+			}
+			spent += n
+			addField(msg, tag.fieldID, field)
+			pos += n
+			continue
+		}
 		switch tag.typ {
 		case Varint:
-			x, n := proto.DecodeVarint(data[pos:])
+			x, n, err := consumeVarint(data[pos:])
+			if err != nil {
+				return nil, 0, err
+			}
+			if opts.overBudget(spent, n) {
+				return &msg, start, ErrBudgetExceeded
+			}
+			spent += n
 			addField(msg, tag.fieldID, Field{
-				numeric: &x,
+				numeric:  &x,
+				wireType: tag.typ,
+				span:     opts.span(pos, pos+n),
 			})
 			pos += n
 		case B32:
-			buf := proto.NewBuffer(data[pos:])
-			x, err := buf.DecodeFixed32()
-			if err != nil {
-				return nil, 0, err
+			v, n := protowire.ConsumeFixed32(data[pos:])
+			if n < 0 {
+				return nil, 0, protowire.ParseError(n)
+			}
+			if opts.overBudget(spent, n) {
+				return &msg, start, ErrBudgetExceeded
 			}
+			spent += n
+			x := uint64(v)
 			addField(msg, tag.fieldID, Field{
-				numeric: &x,
+				numeric:  &x,
+				wireType: tag.typ,
+				span:     opts.span(pos, pos+n),
 			})
-			pos += 4
+			pos += n
 		case B64:
-			buf := proto.NewBuffer(data[pos:])
-			x, err := buf.DecodeFixed64()
-			if err != nil {
-				return nil, 0, err
+			x, n := protowire.ConsumeFixed64(data[pos:])
+			if n < 0 {
+				return nil, 0, protowire.ParseError(n)
 			}
+			if opts.overBudget(spent, n) {
+				return &msg, start, ErrBudgetExceeded
+			}
+			spent += n
 			addField(msg, tag.fieldID, Field{
-				numeric: &x,
+				numeric:  &x,
+				wireType: tag.typ,
+				span:     opts.span(pos, pos+n),
 			})
-			pos += 8
+			pos += n
 		case LengthDelim:
-			x, n := proto.DecodeVarint(data[pos:])
+			x, n, err := consumeVarint(data[pos:])
+			if err != nil {
+				return nil, 0, err
+			}
 			pos += n
-			if pos+int(x) > len(data) {
+			if err := checkLengthDelim(x); err != nil {
+				return nil, 0, err
+			}
+			if uint64(len(data)-pos) < x {
 				return nil, 0, fmt.Errorf("Not enough bytes for length delimited field, wanted %d but only found %d", x, len(data)-pos)
 			}
+			if opts.overBudget(spent, n+int(x)) {
+				return &msg, start, ErrBudgetExceeded
+			}
+			spent += n + int(x)
 			content := data[pos : pos+int(x)]
 			pos += int(x)
-			if subMsg, _, err := ParseProto(content); err == nil {
-				addField(msg, tag.fieldID, Field{
-					message: subMsg,
-				})
-			} else if utf8.Valid(content) {
-				str := string(content)
-				addField(msg, tag.fieldID, Field{
-					string: &str,
-				})
-			} else {
-				addField(msg, tag.fieldID, Field{
-					bytes: &content,
-				})
+			if max := opts.MaxFieldSizes[tag.fieldID]; max > 0 && len(content) > max {
+				err := &FieldTooLargeError{ID: tag.fieldID, MaxBytes: max, ActualBytes: len(content)}
+				if opts.Strict {
+					return nil, 0, err
+				}
+				opts.warn(err)
+				continue
+			}
+			if max, ok := opts.MaxFieldLengths[tag.fieldID]; ok && len(content) > max {
+				err := fmt.Errorf("Field %d is %d bytes long, the maximum is %d", tag.fieldID, len(content), max)
+				if opts.Strict {
+					return nil, 0, err
+				}
+				opts.warn(err)
+				content = content[:max]
+			}
+			if containsID(opts.PackedFields, tag.fieldID) {
+				if err := addPacked(msg, tag.fieldID, content, opts.at(pos-int(x))); err != nil {
+					return nil, 0, err
+				}
+				continue
+			}
+			field, err := parseLengthDelim(content, opts.at(pos-int(x)), tag.fieldID)
+			if err != nil {
+				return nil, 0, err
+			}
+			field.span = opts.span(pos-int(x), pos-int(x)+len(content))
+			addField(msg, tag.fieldID, field)
+		case SGroup:
+			subMsg, n, err := parseGroup(data[pos:], opts.at(pos), tag.fieldID)
+			if err != nil {
+				return nil, 0, err
+			}
+			if opts.overBudget(spent, n) {
+				return &msg, start, ErrBudgetExceeded
 			}
+			spent += n
+			addField(msg, tag.fieldID, Field{
+				message:  subMsg,
+				wireType: SGroup,
+				span:     opts.span(pos, pos+n-protowire.SizeTag(protowire.Number(tag.fieldID))),
+			})
+			pos += n
+		case EGroup:
+			if !inGroup {
+				return nil, 0, fmt.Errorf("Unexpected end of group for field %d", tag.fieldID)
+			}
+			if tag.fieldID != groupID {
+				return nil, 0, fmt.Errorf("Mismatched end of group, wanted field %d but found %d", groupID, tag.fieldID)
+			}
+			return &msg, pos, nil
 		}
 	}
+	if inGroup {
+		return nil, 0, fmt.Errorf("Unclosed group for field %d", groupID)
+	}
 	return &msg, pos, nil
 }
 
+func addPacked(msg Message, id uint64, content []byte, opts ParseOptions) error {
+	for pos := 0; pos < len(content); {
+		x, n, err := consumeVarint(content[pos:])
+		if err != nil {
+			return fmt.Errorf("Invalid packed field %d: %v", id, err)
+		}
+		addField(msg, id, Field{
+			numeric:  &x,
+			wireType: Varint,
+			span:     opts.span(pos, pos+n),
+		})
+		pos += n
+	}
+	return nil
+}
+
+func parseLengthDelim(content []byte, opts ParseOptions, id uint64) (Field, error) {
+	if containsID(opts.ForceBytes, id) {
+		return Field{
+			bytes:    &content,
+			wireType: LengthDelim,
+		}, nil
+	}
+	if containsID(opts.ForceString, id) {
+		str := string(content)
+		return Field{
+			string:   &str,
+			wireType: LengthDelim,
+		}, nil
+	}
+	forced := containsID(opts.ForceMessage, id) || containsID(opts.ForceMessageDeep, id)
+	if (opts.NoRecurse || opts.StringsOnly) && !forced {
+		if opts.StringsOnly && utf8.Valid(content) {
+			str := string(content)
+			return Field{
+				string:   &str,
+				wireType: LengthDelim,
+			}, nil
+		}
+		return Field{
+			bytes:    &content,
+			wireType: LengthDelim,
+		}, nil
+	}
+	var fieldCount int
+	if opts.fieldCount != nil {
+		fieldCount = *opts.fieldCount
+	}
+	subMsg, _, err := ParseProtoWithOptions(content, opts.child(id))
+	if err == nil {
+		return Field{
+			message:  subMsg,
+			wireType: LengthDelim,
+			content:  content,
+		}, nil
+	}
+	var forceErr *forceMessageError
+	var limitErr *limitError
+	var sizeErr *FieldTooLargeError
+	if errors.As(err, &forceErr) || errors.As(err, &limitErr) || errors.As(err, &sizeErr) {
+		return Field{}, err
+	}
+	if opts.fieldCount != nil {
+		// fields seen in a failed sub-message attempt don't count
+		*opts.fieldCount = fieldCount
+	}
+	if forced {
+		if de, ok := err.(*depthError); ok {
+			return Field{}, &depthError{path: de.path, err: &forceMessageError{id: id, err: de.err}}
+		}
+		return Field{}, &forceMessageError{id: id, err: err}
+	}
+	if utf8.Valid(content) {
+		str := string(content)
+		return Field{
+			string:   &str,
+			wireType: LengthDelim,
+		}, nil
+	}
+	return Field{
+		bytes:    &content,
+		wireType: LengthDelim,
+	}, nil
+}
+
+type forceMessageError struct {
+	id  uint64
+	err error
+}
+
+func (e *forceMessageError) Error() string {
+	return fmt.Sprintf("Field %d must be a sub-message: %v", e.id, e.err)
+}
+
+// oneofConflict records the field of tag in seen, returning the field it
+// conflicts with if another field of its oneof group came first
+func oneofConflict(groups [][]uint64, seen map[int]uint64, tag *Tag) (uint64, bool) {
+	if tag.typ == EGroup {
+		return 0, false
+	}
+	for i, group := range groups {
+		if !containsID(group, tag.fieldID) {
+			continue
+		}
+		if first, ok := seen[i]; ok && first != tag.fieldID {
+			return first, true
+		}
+		seen[i] = tag.fieldID
+	}
+	return 0, false
+}
+
+type MissingRequiredFieldError struct {
+	ID uint64
+}
+
+func (e *MissingRequiredFieldError) Error() string {
+	return fmt.Sprintf("Required field %d is missing", e.ID)
+}
+
+type FieldTooLargeError struct {
+	ID          uint64
+	MaxBytes    int
+	ActualBytes int
+}
+
+func (e *FieldTooLargeError) Error() string {
+	return fmt.Sprintf("Field %d is %d bytes long, larger than its limit of %d", e.ID, e.ActualBytes, e.MaxBytes)
+}
+
+type limitError struct {
+	limit string
+	max   int
+}
+
+func (e *limitError) Error() string {
+	return fmt.Sprintf("Message exceeds the maximum %s of %d", e.limit, e.max)
+}
+
+type depthError struct {
+	path []uint64
+	err  error
+}
+
+func (e *depthError) Error() string {
+	ids := make([]string, len(e.path))
+	for i, id := range e.path {
+		ids[i] = strconv.FormatUint(id, 10)
+	}
+	return fmt.Sprintf("at field %s: %v", strings.Join(ids, "."), e.err)
+}
+
+func (e *depthError) Unwrap() error {
+	return e.err
+}
+
+// pathError adds the DepthContext path to an error from a sub-message,
+// unless a deeper sub-message already has
+func (opts ParseOptions) pathError(err error) error {
+	var de *depthError
+	if err == nil || len(opts.path) == 0 || errors.As(err, &de) {
+		return err
+	}
+	return &depthError{path: opts.path, err: err}
+}
+
+// depthLimitError is the error for data nested past MaxDepth. Unless it is
+// a group, data that doesn't parse as a message even without recursing is
+// likely a string or bytes, so the error then isn't a limitError and
+// parseLengthDelim falls back to those.
+func (opts ParseOptions) depthLimitError(data []byte, inGroup bool) error {
+	err := &limitError{limit: "depth", max: opts.MaxDepth}
+	if inGroup {
+		return err
+	}
+	flat := opts
+	flat.NoRecurse = true
+	flat.ForceMessage, flat.ForceMessageDeep = nil, nil
+	flat.Warn = nil
+	// groups still nest, one level at most
+	flat.depth, flat.MaxDepth = 0, 1
+	msg, _, flatErr := parseMessage(data, flat, 0, false)
+	var limitErr *limitError
+	if (flatErr == nil && len(*msg) > 0) || errors.As(flatErr, &limitErr) {
+		return err
+	}
+	return fmt.Errorf("Content past the maximum depth of %d is not a message", opts.MaxDepth)
+}
+
+func (opts ParseOptions) overBudget(spent, n int) bool {
+	return opts.ByteBudget != nil && spent+n > *opts.ByteBudget
+}
+
+// at returns the options for parsing data that starts pos bytes into the
+// current data
+func (opts ParseOptions) at(pos int) ParseOptions {
+	opts.offset += pos
+	return opts
+}
+
+func (opts ParseOptions) span(start, end int) *byteRange {
+	if !opts.annotate {
+		return nil
+	}
+	return &byteRange{opts.offset + start, opts.offset + end}
+}
+
+func (opts ParseOptions) warn(err error) {
+	if opts.Warn != nil {
+		opts.Warn(err)
+	}
+}
+
+func (opts ParseOptions) nested() ParseOptions {
+	opts.ByteBudget = nil
+	opts.ForceBytes = nil
+	opts.ForceString = nil
+	opts.ForceMessage = nil
+	opts.PackedFields = nil
+	opts.Schema = nil
+	opts.OneofGroups = nil
+	opts.RequiredFields = nil
+	opts.unknownFields = nil
+	opts.depth++
+	return opts
+}
+
+// child returns the options for parsing the contents of field id
+func (opts ParseOptions) child(id uint64) ParseOptions {
+	if opts.DepthContext {
+		opts.path = append(opts.path[:len(opts.path):len(opts.path)], id)
+	}
+	return opts.nested()
+}
+
+// maxVarintBytes is the longest encoding of a 64-bit varint
+const maxVarintBytes = 10
+
+// maxLengthDelim bounds the length of a length-delimited field, well past
+// any real gRPC message, so that lengths always fit in an int
+const maxLengthDelim = math.MaxInt32
+
+func checkLengthDelim(x uint64) error {
+	if x > maxLengthDelim {
+		return fmt.Errorf("Length-delimited field length %d exceeds the maximum of %d", x, maxLengthDelim)
+	}
+	return nil
+}
+
+func consumeVarint(data []byte) (uint64, int, error) {
+	if len(data) < 1 {
+		return 0, 0, fmt.Errorf("Expected a varint but found no bytes")
+	}
+	x, n := protowire.ConsumeVarint(data)
+	if n < 0 {
+		return 0, 0, protowire.ParseError(n)
+	}
+	if n > maxVarintBytes {
+		return 0, 0, fmt.Errorf("Varint is %d bytes long, the maximum is %d", n, maxVarintBytes)
+	}
+	return x, n, nil
+}
+
+// parseTag is ParseTag, extended to accept any wire type with a handler
+func (opts ParseOptions) parseTag(data []byte) (*Tag, int, error) {
+	if len(opts.WireTypeHandlers) > 0 {
+		x, n, err := consumeVarint(data)
+		if err != nil {
+			return nil, 0, err
+		}
+		if _, ok := opts.WireTypeHandlers[x&7]; ok {
+			return &Tag{
+				fieldID: x >> 3,
+				typ:     x & 7,
+			}, n, nil
+		}
+	}
+	return ParseTag(data)
+}
+
 func ParseTag(data []byte) (*Tag, int, error) {
-	x, n := proto.DecodeVarint(data)
+	x, n, err := consumeVarint(data)
+	if err != nil {
+		return nil, 0, err
+	}
 	typ := x & 7
 	id := x >> 3
 	switch typ {
@@ -133,6 +878,10 @@ func ParseTag(data []byte) (*Tag, int, error) {
 		fallthrough
 	case LengthDelim:
 		fallthrough
+	case SGroup:
+		fallthrough
+	case EGroup:
+		fallthrough
 	case B32:
 		return &Tag{
 			fieldID: id,
@@ -143,44 +892,360 @@ func ParseTag(data []byte) (*Tag, int, error) {
 	}
 }
 
-func Render(m *Message) string {
-	out := []string{}
-	for id, fields := range *m {
-		if len(fields) == 1 {
-			out = append(out, fmt.Sprintf("\"%d\":%s", id, RenderField(fields[0])))
-		} else {
-			repeated := []string{}
-			for _, f := range fields {
-				repeated = append(repeated, RenderField(f))
-			}
-			out = append(out, fmt.Sprintf("\"%d\":[%s]", id, strings.Join(repeated, ",")))
+func main() {
+	frame := flag.Int("frame", 0, "only render the Nth frame (1-indexed)")
+	frames := flag.String("frames", "", "only render frames M-N (1-indexed, inclusive)")
+	merge := flag.Bool("merge", false, "merge the messages from all input files into one")
+	compare := flag.Bool("compare", false, "show the differences between consecutive input files")
+	diff := flag.Bool("diff", false, "show the differences between exactly two input files")
+	colorMode := flag.String("color", "auto", "color --diff output: auto, always or never")
+	cat := flag.Bool("cat", false, "render each input file in turn, separated by ---")
+	separator := flag.String("separator", "\\n", "separator written after each frame (backslash escapes allowed)")
+	framePrefix := flag.String("frame-prefix", "", "format written before each frame, given the frame number and, as %[2]d, its size")
+	watch := flag.Bool("watch", false, "render frames from stdin as they arrive, labelled with their frame numbers")
+	var output string
+	flag.StringVar(&output, "output", "-", "write output to this file instead of stdout")
+	flag.StringVar(&output, "o", "-", "shorthand for --output")
+	appendOutput := flag.Bool("append", false, "append to the --output file instead of truncating it")
+	filterExpr := flag.String("filter", "", "only keep frames matching an expression such as 'field(1) == \"hello\" && field(2) > 100'")
+	field := flag.String("field", "", "only render the values at this field path, e.g. 1.2 or 1[2]")
+	indexRepeated := flag.Bool("index-repeated", false, "render repeated fields as indexed keys instead of arrays")
+	typeHints := flag.String("type-hints", "", "comma-separated field_id:type hints, e.g. 1:float,2:sint32")
+	schemaFile := flag.String("schema-file", "", "JSON file of field names and types, e.g. {\"1\": {\"name\": \"user_id\", \"type\": \"string\"}}")
+	reflectAddr := flag.String("reflect", "", "fetch descriptors from the gRPC server reflection service at this address")
+	reflectV1alpha := flag.Bool("reflect-v1alpha", false, "use the older v1alpha reflection service with --reflect")
+	messageType := flag.String("message-type", "", "with --reflect, the fully-qualified message type of each frame")
+	serve := flag.String("serve", "", "serve a web page and a /parse endpoint at this address, e.g. :8080")
+	noRecurse := flag.Bool("no-recurse", false, "render every length-delimited field as bytes")
+	stringsOnly := flag.Bool("strings-only", false, "render length-delimited fields as strings or bytes, never as sub-messages")
+	auto := flag.Bool("auto", false, "detect whether the input is gRPC, raw proto, gRPC-Web or base64 gRPC-Web text")
+	explain := flag.Bool("explain", false, "describe what each byte of the input means")
+	verbose := flag.Bool("verbose", false, "show the byte range of each value in the input, as in \"1[2:7]\"; with --dump, render each frame in full")
+	dump := flag.Bool("dump", false, "summarize each frame on one line instead of rendering it")
+	direction := flag.String("direction", "", "with --dump, label frames as a request or response")
+	maxFrames := flag.Int("max-frames", 0, "with --dump, stop after this many frames")
+	templateText := flag.String("template", "", "render each frame with a Go text/template, e.g. '{{fields 1 | first | string}}'")
+	logFormat := flag.String("log-format", "raw", "render frames as raw JSON, or as slog records in text or json format")
+	roundTrip := flag.Bool("roundtrip", false, "check that each frame parses the same after being re-encoded")
+	ndjson := flag.Bool("ndjson", false, "render every frame as JSON on its own line, for log processing tools")
+	histogram := flag.Bool("histogram", false, "show a bar chart of frame sizes instead of rendering the frames")
+	humanReadable := flag.Bool("human-readable", false, "show byte counts like 1.2 KiB instead of 1234")
+	si := flag.Bool("si", false, "with --human-readable, use powers of 1000 (KB, MB) instead of 1024 (KiB, MiB)")
+	maxDepth := flag.Int("max-depth", 100, "maximum sub-message nesting depth, 0 for unlimited")
+	maxSize := flag.Int("max-size", 100<<20, "maximum input size in bytes, 0 for unlimited")
+	maxFields := flag.Int("max-fields", 10000, "maximum number of fields in a message across all levels, 0 for unlimited")
+	flag.Parse()
+
+	popts := ParseOptions{
+		MaxDepth:    *maxDepth,
+		MaxSize:     *maxSize,
+		MaxFields:   *maxFields,
+		annotate:    *verbose,
+		NoRecurse:   *noRecurse,
+		StringsOnly: *stringsOnly,
+	}
+	hints, err := parseTypeHints(*typeHints, &popts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	var names map[uint64]string
+	if *schemaFile != "" {
+		if names, err = loadSchemaFile(*schemaFile, hints, &popts); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
 	}
-	return fmt.Sprintf("{%s}", strings.Join(out, ","))
-}
 
-func RenderField(f Field) string {
-	if f.numeric != nil {
-		return fmt.Sprintf("%d", *f.numeric)
+	opts := RenderOptions{
+		Separator:      unescapeFlag(*separator),
+		FramePrefix:    unescapeFlag(*framePrefix),
+		IndexRepeated:  *indexRepeated,
+		TypeHints:      hints,
+		FieldNames:     names,
+		HumanReadable:  *humanReadable,
+		ShowByteRanges: *verbose,
+		SI:             *si,
 	}
-	if f.string != nil {
-		return fmt.Sprintf("\"%s\"", *f.string)
+	var path []pathElem
+	if *field != "" {
+		var err error
+		if path, err = parsePath(*field); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
 	}
-	if f.message != nil {
-		return Render(f.message)
+
+	var tmpl *MessageTemplate
+	if *templateText != "" {
+		if tmpl, err = NewMessageTemplate(*templateText); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
 	}
-	if f.bytes != nil {
-		return fmt.Sprintf("%x", f.bytes)
+	var filter *Filter
+	if *filterExpr != "" {
+		if filter, err = ParseFilter(*filterExpr); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
 	}
-	return ""
-}
 
-func main() {
+	first, last, err := parseFrameRange(*frame, *frames)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if countSet(*merge, *compare, *diff, *cat, *explain, *dump, *histogram, *ndjson, *roundTrip) > 1 {
+		fmt.Fprintln(os.Stderr, "--merge, --compare, --diff, --cat, --explain, --dump, --histogram, --ndjson and --roundtrip cannot be used together")
+		os.Exit(1)
+	}
+
+	errLogger, _ := newLogger(*logFormat, os.Stderr)
+	// fail exits after reporting err, for the input name if it is set, as
+	// a log record when --log-format asks for one
+	fail := func(name string, err error) {
+		switch {
+		case errLogger != nil && name != "":
+			errLogger.Error("grpc", "input", name, "error", err)
+		case errLogger != nil:
+			errLogger.Error("grpc", "error", err)
+		case name != "":
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		default:
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.Exit(1)
+	}
+	if *diff && flag.NArg() != 2 {
+		fail("", errors.New("--diff requires exactly two input files"))
+	}
+	color, err := useColor(*colorMode, output)
+	if err != nil {
+		fail("", err)
+	}
+	out, err := openOutput(output, *appendOutput)
+	if err != nil {
+		fail("", err)
+	}
+	logger, err := newLogger(*logFormat, out)
+	if err != nil {
+		fail("", err)
+	}
+
+	if *serve != "" {
+		if err := http.ListenAndServe(*serve, ParseHandler(popts)); err != nil {
+			fail("", err)
+		}
+		return
+	}
+
+	var resolver DescriptorResolver
+	if *reflectAddr != "" {
+		if *messageType == "" {
+			fail("", errors.New("--reflect requires --message-type"))
+		}
+		conn, err := grpc.NewClient(*reflectAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			fail("", err)
+		}
+		defer conn.Close()
+		if *reflectV1alpha {
+			resolver = NewReflectionResolverV1alpha(conn)
+		} else {
+			resolver = NewReflectionResolver(conn)
+		}
+	}
 
-	data, _ := ioutil.ReadAll(os.Stdin)
+	if *watch {
+		if flag.NArg() > 0 || first != 1 || last != 0 {
+			fail("", errors.New("--watch reads every frame from stdin and cannot be combined with input files or frame selection"))
+		}
+		if err := WatchGrpc(os.Stdin, out, opts, popts); err != nil {
+			fail("", err)
+		}
+		closeOutput(out)
+		return
+	}
 
 	//data := []byte{0x00, 0x00, 0x00, 0x00, 0x1f, 0x0a, 0x0a, 0x0a, 0x08, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x12, 0x0f, 0x32, 0x0d, 0x0a, 0x0b, 0x0a, 0x09, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x5f, 0x76, 0x31, 0x1a, 0x00}
 
-	msg, _, _ := ParseGrpc(data)
-	fmt.Println(Render(msg))
+	inputs, err := readInputs(flag.Args())
+	if err != nil {
+		fail("", err)
+	}
+	for _, in := range inputs {
+		if *maxSize > 0 && len(in.data) > *maxSize {
+			fail("", fmt.Errorf("Input %s is %d bytes, larger than --max-size %d", in.name, len(in.data), *maxSize))
+		}
+	}
+	if *auto {
+		for i := range inputs {
+			if inputs[i].data, err = toGrpcFrames(inputs[i].data, popts); err != nil {
+				fail(inputs[i].name, err)
+			}
+		}
+	}
+	if *dump {
+		for i, in := range inputs {
+			if i > 0 {
+				fmt.Fprintln(out, "---")
+			}
+			err := GrpcDump(bytes.NewReader(in.data), out, DumpOptions{
+				Verbose:       *verbose,
+				Direction:     *direction,
+				MaxFrames:     *maxFrames,
+				ParseOptions:  popts,
+				HumanReadable: *humanReadable,
+				SI:            *si,
+			})
+			if err != nil {
+				fail(in.name, err)
+			}
+		}
+		closeOutput(out)
+		return
+	}
+
+	if *roundTrip {
+		failed := false
+		frameOpts := popts
+		frameOpts.RetainRaw = true
+		for i, in := range inputs {
+			if i > 0 {
+				fmt.Fprintln(out, "---")
+			}
+			for n, offset := 1, 0; offset < len(in.data); n++ {
+				frame, size, err := ParseGrpcFrameWithOptions(in.data[offset:], frameOpts)
+				if err != nil {
+					fail(in.name, fmt.Errorf("Frame %d: %v", n, err))
+				}
+				offset += size
+				switch err := ValidateRoundTripWithOptions(frame.RawPayload, popts); {
+				case frame.Compressed:
+					fmt.Fprintf(out, "Frame %d: compressed, skipped\n", n)
+				case err != nil:
+					fmt.Fprintf(out, "Frame %d: %v\n", n, err)
+					failed = true
+				default:
+					fmt.Fprintf(out, "Frame %d: ok\n", n)
+				}
+			}
+		}
+		closeOutput(out)
+		if failed {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *explain {
+		for i, in := range inputs {
+			if i > 0 {
+				fmt.Fprintln(out, "---")
+			}
+			explanation, err := ExplainGrpc(in.data, popts)
+			fmt.Fprint(out, explanation)
+			if err != nil {
+				fail(in.name, err)
+			}
+		}
+		closeOutput(out)
+		return
+	}
+
+	files := make([][]*Message, len(inputs))
+	for i, in := range inputs {
+		msgs, err := selectFrames(in.data, first, last, popts)
+		if err != nil && errLogger == nil && len(inputs) == 1 {
+			fail("", err)
+		}
+		if err != nil {
+			fail(in.name, err)
+		}
+		if filter != nil {
+			matched := []*Message{}
+			for _, msg := range msgs {
+				if filter.Match(msg) {
+					matched = append(matched, msg)
+				}
+			}
+			msgs = matched
+		}
+		files[i] = msgs
+	}
+
+	switch {
+	case *merge:
+		all := []*Message{}
+		for _, msgs := range files {
+			all = append(all, msgs...)
+		}
+		fmt.Fprintln(out, Render(MergeAll(all)))
+	case *diff:
+		diffs := Diff(MergeAll(files[0]), MergeAll(files[1]))
+		if len(diffs) == 0 {
+			break
+		}
+		if color {
+			fmt.Fprintln(out, RenderDiffColor(diffs))
+		} else {
+			fmt.Fprintln(out, RenderDiff(diffs))
+		}
+	case *histogram:
+		all := []*Message{}
+		for _, msgs := range files {
+			all = append(all, msgs...)
+		}
+		WriteHistogramWithOptions(out, SizeHistogram(all), opts)
+	case *ndjson:
+		for _, msgs := range files {
+			RenderNDJSON(msgs, out)
+		}
+	case *compare:
+		for i := 1; i < len(files); i++ {
+			diffs := Diff(MergeAll(files[i-1]), MergeAll(files[i]))
+			if len(diffs) == 0 {
+				continue
+			}
+			fmt.Fprintf(out, "--- %s\n+++ %s\n", inputs[i-1].name, inputs[i].name)
+			fmt.Fprintln(out, RenderDiff(diffs))
+		}
+	default:
+		for i, msgs := range files {
+			if *cat && i > 0 {
+				fmt.Fprintln(out, "---")
+			}
+			for j, msg := range msgs {
+				if path != nil {
+					id := path[len(path)-1].id
+					for _, f := range getPath(msg, path) {
+						fmt.Fprint(out, opts.framePrefix(first+j, EncodedSize(msg))+RenderFieldWithOptions(id, f, opts)+opts.Separator)
+					}
+					continue
+				}
+				if tmpl != nil {
+					fmt.Fprint(out, opts.framePrefix(first+j, EncodedSize(msg)))
+					if err := tmpl.Execute(out, msg); err != nil {
+						fail("", fmt.Errorf("Frame %d: %v", first+j, err))
+					}
+					fmt.Fprint(out, opts.Separator)
+					continue
+				}
+				if logger != nil {
+					attrs := append([]slog.Attr{slog.String("input", inputs[i].name), slog.Int("frame", first+j)}, MessageAttrs(msg)...)
+					logger.LogAttrs(context.Background(), slog.LevelInfo, "grpc", attrs...)
+					continue
+				}
+				rendered := RenderWithOptions(msg, opts)
+				if resolver != nil {
+					if rendered, err = RenderWithDescriptor(msg, resolver, *messageType); err != nil {
+						fail("", err)
+					}
+				}
+				fmt.Fprint(out, opts.framePrefix(first+j, EncodedSize(msg))+rendered+opts.Separator)
+			}
+		}
+	}
+	closeOutput(out)
 }
@@ -0,0 +1,97 @@
+package main
+
+import "fmt"
+
+// Map converts a message into plain Go values: uint64 for numbers, string,
+// []byte, map[uint64]interface{} for sub-messages, and []interface{} of
+// those for repeated fields. Wire types are not kept, so FromMap turns
+// fixed-width numbers back into varints and groups into sub-messages.
+func Map(m *Message) map[uint64]interface{} {
+	result := map[uint64]interface{}{}
+	if m == nil {
+		return result
+	}
+	for id, fields := range *m {
+		if len(fields) == 1 {
+			result[id] = mapValue(fields[0])
+			continue
+		}
+		values := make([]interface{}, len(fields))
+		for i, f := range fields {
+			values[i] = mapValue(f)
+		}
+		result[id] = values
+	}
+	return result
+}
+
+func mapValue(f Field) interface{} {
+	switch {
+	case f.numeric != nil:
+		return *f.numeric
+	case f.string != nil:
+		return *f.string
+	case f.bytes != nil:
+		return *f.bytes
+	case f.message != nil:
+		return Map(f.message)
+	default:
+		return nil
+	}
+}
+
+// FromMap builds a message from values as returned by Map. Numbers may be
+// any integer type, with negative ones encoded as two's complement like
+// int64 fields.
+func FromMap(m map[uint64]interface{}) (*Message, error) {
+	msg := NewMessage()
+	for id, value := range m {
+		values, repeated := value.([]interface{})
+		if !repeated {
+			values = []interface{}{value}
+		}
+		for _, v := range values {
+			f, err := mapField(id, v)
+			if err != nil {
+				return nil, err
+			}
+			msg.Add(id, f)
+		}
+	}
+	return &msg, nil
+}
+
+func mapField(id uint64, value interface{}) (Field, error) {
+	var x uint64
+	switch v := value.(type) {
+	case uint64:
+		x = v
+	case uint32:
+		x = uint64(v)
+	case uint:
+		x = uint64(v)
+	case int:
+		x = uint64(v)
+	case int32:
+		x = uint64(v)
+	case int64:
+		x = uint64(v)
+	case bool:
+		if v {
+			x = 1
+		}
+	case string:
+		return Field{string: &v, wireType: LengthDelim}, nil
+	case []byte:
+		return Field{bytes: &v, wireType: LengthDelim}, nil
+	case map[uint64]interface{}:
+		sub, err := FromMap(v)
+		if err != nil {
+			return Field{}, err
+		}
+		return Field{message: sub, wireType: LengthDelim}, nil
+	default:
+		return Field{}, fmt.Errorf("Field %d has unsupported type %T", id, value)
+	}
+	return Field{numeric: &x, wireType: Varint}, nil
+}
@@ -0,0 +1,22 @@
+package main
+
+// Merge appends every field of src to dst, which matches parsing the
+// concatenation of both encodings
+func Merge(dst, src *Message) {
+	if src == nil {
+		return
+	}
+	for _, id := range src.FieldIDs() {
+		for _, f := range (*src)[id] {
+			dst.Add(id, f)
+		}
+	}
+}
+
+func MergeAll(msgs []*Message) *Message {
+	merged := NewMessage()
+	for _, m := range msgs {
+		Merge(&merged, m)
+	}
+	return &merged
+}
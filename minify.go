@@ -0,0 +1,37 @@
+package main
+
+// Minify returns a copy of m without the fields holding proto3 default
+// values: zero numbers, empty strings and bytes, and sub-messages that are
+// empty once minified themselves
+func Minify(m *Message) *Message {
+	minified := NewMessage()
+	if m == nil {
+		return &minified
+	}
+	for id, fields := range *m {
+		for _, f := range fields {
+			switch {
+			case f.numeric != nil && *f.numeric == 0,
+				f.string != nil && *f.string == "",
+				f.bytes != nil && len(*f.bytes) == 0:
+				continue
+			case f.message != nil:
+				f.message, f.content = Minify(f.message), nil
+				if len(*f.message) == 0 {
+					continue
+				}
+			}
+			minified.Add(id, f)
+		}
+	}
+	return &minified
+}
+
+// MinifyEncoded re-encodes a message without its default values
+func MinifyEncoded(data []byte) ([]byte, error) {
+	m, _, err := ParseProto(data)
+	if err != nil {
+		return nil, err
+	}
+	return Encode(Minify(m))
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// RenderMessagePack encodes a message as a MessagePack map keyed by field
+// ID. The encoder's own map support doesn't sort integer keys, so maps are
+// written field by field in ascending ID order.
+func RenderMessagePack(m *Message) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.UseCompactInts(true)
+	if err := encodeMsgpackMessage(enc, m); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeMsgpackMessage(enc *msgpack.Encoder, m *Message) error {
+	if m == nil {
+		return enc.EncodeMapLen(0)
+	}
+	if err := enc.EncodeMapLen(len(*m)); err != nil {
+		return err
+	}
+	for _, id := range m.FieldIDs() {
+		fields := (*m)[id]
+		if err := enc.EncodeUint(id); err != nil {
+			return err
+		}
+		if len(fields) == 1 {
+			if err := encodeMsgpackField(enc, id, fields[0]); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := enc.EncodeArrayLen(len(fields)); err != nil {
+			return err
+		}
+		for _, f := range fields {
+			if err := encodeMsgpackField(enc, id, f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func encodeMsgpackField(enc *msgpack.Encoder, id uint64, f Field) error {
+	switch {
+	case f.numeric != nil:
+		return enc.EncodeUint(*f.numeric)
+	case f.string != nil:
+		return enc.EncodeString(*f.string)
+	case f.bytes != nil:
+		return enc.EncodeBytes(*f.bytes)
+	case f.message != nil:
+		return encodeMsgpackMessage(enc, f.message)
+	default:
+		return fmt.Errorf("Cannot encode field %d, it has no value", id)
+	}
+}
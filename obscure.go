@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ObscurityWarning flags a field whose value may have been parsed or
+// encoded in a misleading way; Confidence runs from 0 (a weak hunch) to 1
+type ObscurityWarning struct {
+	FieldID     uint64
+	Description string
+	Confidence  float64
+}
+
+func (w ObscurityWarning) String() string {
+	return fmt.Sprintf("field %d: %s (confidence %.1f)", w.FieldID, w.Description, w.Confidence)
+}
+
+// Obscure reports fields that are hard to interpret, checking sub-messages
+// recursively
+func Obscure(m *Message) []ObscurityWarning {
+	warnings := []ObscurityWarning{}
+	if m == nil {
+		return warnings
+	}
+	for _, id := range m.FieldIDs() {
+		for _, f := range (*m)[id] {
+			switch {
+			case f.bytes != nil:
+				trimmed := strings.TrimSpace(string(*f.bytes))
+				if (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) && json.Valid([]byte(trimmed)) {
+					warnings = append(warnings, ObscurityWarning{id, "bytes value is valid JSON, it may be better as a string", 0.9})
+				}
+			case f.string != nil:
+				if confidence := formConfidence(*f.string); confidence > 0 {
+					warnings = append(warnings, ObscurityWarning{id, "string value looks URL-encoded, it may hold form fields", confidence})
+				}
+			case f.numeric != nil:
+				if f.wireType == Varint && looksLikeTag(*f.numeric) {
+					warnings = append(warnings, ObscurityWarning{id, fmt.Sprintf("value %d is also a valid tag, it may be a misread nested encoding", *f.numeric), 0.2})
+				}
+			case f.message != nil:
+				if f.wireType == LengthDelim {
+					if raw, err := f.messageBytes(); err == nil && len(raw) > 0 && printable(raw) {
+						warnings = append(warnings, ObscurityWarning{id, "value parses as a sub-message but is also printable UTF-8, it may be a string", 0.6})
+					}
+				}
+				warnings = append(warnings, Obscure(f.message)...)
+			}
+		}
+	}
+	return warnings
+}
+
+// formConfidence rates how likely s is to be URL-encoded form data, from
+// 0 for not at all up to 0.8 for several key=value pairs
+func formConfidence(s string) float64 {
+	if !strings.Contains(s, "=") || strings.ContainsAny(s, " \t\n") {
+		return 0
+	}
+	values, err := url.ParseQuery(s)
+	if err != nil {
+		return 0
+	}
+	for key := range values {
+		if key == "" {
+			return 0
+		}
+	}
+	if strings.Contains(s, "&") {
+		return 0.8
+	}
+	return 0.4
+}
+
+// looksLikeTag reports whether x is a tag with a valid field ID and a wire
+// type other than the rarely used groups
+func looksLikeTag(x uint64) bool {
+	id, typ := x>>3, x&7
+	return id >= 1 && id <= maxFieldID && (typ == Varint || typ == B64 || typ == LengthDelim || typ == B32)
+}
+
+func printable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
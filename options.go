@@ -0,0 +1,107 @@
+package main
+
+type ParseOption func(*ParseOptions)
+
+// DefaultOptions is the starting point for the ParseOption variants of the
+// parse functions; it may be changed once at program startup
+var DefaultOptions ParseOptions
+
+func DefaultParseOptions() ParseOptions {
+	return DefaultOptions
+}
+
+func applyOptions(opts []ParseOption) ParseOptions {
+	o := DefaultParseOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func WithMaxDepth(n int) ParseOption {
+	return func(o *ParseOptions) { o.MaxDepth = n }
+}
+
+func WithMaxSize(n int) ParseOption {
+	return func(o *ParseOptions) { o.MaxSize = n }
+}
+
+func WithMaxFields(n int) ParseOption {
+	return func(o *ParseOptions) { o.MaxFields = n }
+}
+
+func WithMaxFieldID(id uint64) ParseOption {
+	return func(o *ParseOptions) { o.MaxFieldID = id }
+}
+
+func WithMaxFieldLengths(lengths map[uint64]int) ParseOption {
+	return func(o *ParseOptions) { o.MaxFieldLengths = lengths }
+}
+
+func WithMaxFieldSizes(sizes map[uint64]int) ParseOption {
+	return func(o *ParseOptions) { o.MaxFieldSizes = sizes }
+}
+
+func WithStrict() ParseOption {
+	return func(o *ParseOptions) { o.Strict = true }
+}
+
+func WithWarn(warn func(error)) ParseOption {
+	return func(o *ParseOptions) { o.Warn = warn }
+}
+
+func WithByteBudget(n int) ParseOption {
+	return func(o *ParseOptions) { o.ByteBudget = &n }
+}
+
+func WithRetainRaw() ParseOption {
+	return func(o *ParseOptions) { o.RetainRaw = true }
+}
+
+func WithPackedFields(ids ...uint64) ParseOption {
+	return func(o *ParseOptions) { o.PackedFields = appendIDs(o.PackedFields, ids) }
+}
+
+func WithForceBytes(ids ...uint64) ParseOption {
+	return func(o *ParseOptions) { o.ForceBytes = appendIDs(o.ForceBytes, ids) }
+}
+
+func WithForceString(ids ...uint64) ParseOption {
+	return func(o *ParseOptions) { o.ForceString = appendIDs(o.ForceString, ids) }
+}
+
+func WithForceMessage(ids ...uint64) ParseOption {
+	return func(o *ParseOptions) { o.ForceMessage = appendIDs(o.ForceMessage, ids) }
+}
+
+func WithForceMessageDeep(ids ...uint64) ParseOption {
+	return func(o *ParseOptions) { o.ForceMessageDeep = appendIDs(o.ForceMessageDeep, ids) }
+}
+
+func WithSchema(schema []FieldSpec) ParseOption {
+	return func(o *ParseOptions) { o.Schema = schema }
+}
+
+func WithTrackPresence() ParseOption {
+	return func(o *ParseOptions) { o.TrackPresence = true }
+}
+
+func WithOneofGroups(groups ...[]uint64) ParseOption {
+	return func(o *ParseOptions) {
+		o.OneofGroups = append(o.OneofGroups[:len(o.OneofGroups):len(o.OneofGroups)], groups...)
+	}
+}
+
+func WithRequiredFields(ids ...uint64) ParseOption {
+	return func(o *ParseOptions) { o.RequiredFields = appendIDs(o.RequiredFields, ids) }
+}
+
+func WithDepthContext() ParseOption {
+	return func(o *ParseOptions) { o.DepthContext = true }
+}
+
+// appendIDs never writes into the backing array of ids, which may belong to
+// DefaultOptions
+func appendIDs(ids, more []uint64) []uint64 {
+	return append(ids[:len(ids):len(ids)], more...)
+}
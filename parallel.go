@@ -0,0 +1,38 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+// ParseProtoAll parses each blob concurrently, returning the messages and
+// errors in input order. opts.Warn may be called from several goroutines
+// at once.
+func ParseProtoAll(blobs [][]byte, opts ParseOptions) ([]*Message, []error) {
+	msgs := make([]*Message, len(blobs))
+	errs := make([]error, len(blobs))
+	workers := opts.Parallelism
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(blobs) {
+		workers = len(blobs)
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				msgs[i], _, errs[i] = ParseProtoWithOptions(blobs[i], opts)
+			}
+		}()
+	}
+	for i := range blobs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return msgs, errs
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type pathElem struct {
+	id uint64
+	// index selects one value of a repeated field; -1 selects all of them
+	index int
+}
+
+func parsePath(path string) ([]pathElem, error) {
+	if path == "" {
+		return nil, fmt.Errorf("Empty field path")
+	}
+	elems := []pathElem{}
+	for _, part := range strings.Split(path, ".") {
+		elem := pathElem{index: -1}
+		if i := strings.Index(part, "["); i >= 0 {
+			if !strings.HasSuffix(part, "]") {
+				return nil, fmt.Errorf("Invalid field path %q, missing ] in %q", path, part)
+			}
+			index, err := strconv.Atoi(part[i+1 : len(part)-1])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("Invalid field path %q, bad index in %q", path, part)
+			}
+			elem.index = index
+			part = part[:i]
+		}
+		id, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid field path %q, %q is not a field ID", path, part)
+		}
+		elem.id = id
+		elems = append(elems, elem)
+	}
+	return elems, nil
+}
+
+// FieldPath is a path of field IDs from a message into its sub-messages
+type FieldPath []uint64
+
+// NewFieldPath parses a dotted path such as "1.2.3"
+func NewFieldPath(s string) (FieldPath, error) {
+	elems, err := parsePath(s)
+	if err != nil {
+		return nil, err
+	}
+	p := make(FieldPath, len(elems))
+	for i, elem := range elems {
+		if elem.index >= 0 {
+			return nil, fmt.Errorf("Invalid field path %q, a FieldPath cannot select an index", s)
+		}
+		p[i] = elem.id
+	}
+	return p, nil
+}
+
+func (p FieldPath) String() string {
+	ids := make([]string, len(p))
+	for i, id := range p {
+		ids[i] = strconv.FormatUint(id, 10)
+	}
+	return strings.Join(ids, ".")
+}
+
+// Parent returns the path without its last field ID, or nil for an empty
+// path
+func (p FieldPath) Parent() FieldPath {
+	if len(p) == 0 {
+		return nil
+	}
+	return p[:len(p)-1]
+}
+
+// Child returns a new path extending p with id, leaving p unchanged
+func (p FieldPath) Child(id uint64) FieldPath {
+	return append(p[:len(p):len(p)], id)
+}
+
+// GetPath returns the fields at a path, following every value of repeated
+// fields; GetPathIndexed can also pick one value. An empty path matches
+// nothing.
+func GetPath(m *Message, p FieldPath) []Field {
+	if len(p) == 0 {
+		return nil
+	}
+	elems := make([]pathElem, len(p))
+	for i, id := range p {
+		elems[i] = pathElem{id: id, index: -1}
+	}
+	return getPath(m, elems)
+}
+
+// GetPathIndexed returns the fields at a dotted path such as "1.2.3". An
+// element may be indexed, as in "1[2].3", to pick one value of a repeated
+// field; otherwise every value is followed. Invalid paths match nothing.
+func GetPathIndexed(m *Message, path string) []Field {
+	elems, err := parsePath(path)
+	if err != nil {
+		return nil
+	}
+	return getPath(m, elems)
+}
+
+func getPath(m *Message, elems []pathElem) []Field {
+	if m == nil {
+		return nil
+	}
+	elem := elems[0]
+	fields := (*m)[elem.id]
+	if elem.index >= 0 {
+		if elem.index >= len(fields) {
+			return nil
+		}
+		fields = fields[elem.index : elem.index+1]
+	}
+	if len(elems) == 1 {
+		return fields
+	}
+	found := []Field{}
+	for _, f := range fields {
+		if f.message != nil {
+			found = append(found, getPath(f.message, elems[1:])...)
+		}
+	}
+	return found
+}
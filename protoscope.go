@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// RenderProtoscope renders a message in a subset of protoscope notation,
+// one field per line: "1: 42" for varints, "2: \"hello\"" for strings,
+// "3: 0x1234abcd" for bytes, "4: {...}" for sub-messages, "5: !{...}" for
+// groups, and "6i32: 42" and "7i64: 100" for fixed32 and fixed64 values
+func RenderProtoscope(m *Message) string {
+	var sb strings.Builder
+	writeProtoscope(&sb, m, "")
+	return sb.String()
+}
+
+func writeProtoscope(sb *strings.Builder, m *Message, indent string) {
+	if m == nil {
+		return
+	}
+	for _, id := range m.FieldIDs() {
+		for _, f := range (*m)[id] {
+			sb.WriteString(indent)
+			switch {
+			case f.numeric != nil:
+				suffix := ""
+				switch f.wireType {
+				case B32:
+					suffix = "i32"
+				case B64:
+					suffix = "i64"
+				}
+				fmt.Fprintf(sb, "%d%s: %d", id, suffix, *f.numeric)
+			case f.string != nil:
+				fmt.Fprintf(sb, "%d: %s", id, quoteText([]byte(*f.string), true))
+			case f.bytes != nil:
+				fmt.Fprintf(sb, "%d: 0x%x", id, *f.bytes)
+			case f.message != nil:
+				open := "{"
+				if f.wireType == SGroup {
+					open = "!{"
+				}
+				if len(*f.message) == 0 {
+					fmt.Fprintf(sb, "%d: %s}", id, open)
+					break
+				}
+				fmt.Fprintf(sb, "%d: %s\n", id, open)
+				writeProtoscope(sb, f.message, indent+"  ")
+				sb.WriteString(indent + "}")
+			}
+			sb.WriteByte('\n')
+		}
+	}
+}
+
+// ParseProtoscope parses the protoscope notation written by
+// RenderProtoscope, reusing the lexing rules of ParseProtoText for
+// comments, separators and string escapes
+func ParseProtoscope(text string) (*Message, error) {
+	p := &protoscopeParser{textParser{text: text, line: 1}}
+	msg, err := p.parseMessage(false)
+	if err != nil {
+		return nil, fmt.Errorf("Line %d: %v", p.line, err)
+	}
+	return msg, nil
+}
+
+type protoscopeParser struct {
+	textParser
+}
+
+func (p *protoscopeParser) parseMessage(nested bool) (*Message, error) {
+	msg := NewMessage()
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.text) {
+			if nested {
+				return nil, fmt.Errorf("Unclosed message, expected }")
+			}
+			return &msg, nil
+		}
+		if p.text[p.pos] == '}' {
+			if !nested {
+				return nil, fmt.Errorf("Unexpected }")
+			}
+			p.pos++
+			return &msg, nil
+		}
+		name := p.token()
+		if name == "" {
+			return nil, fmt.Errorf("Expected a field ID, found %q", p.text[p.pos:p.pos+1])
+		}
+		wireType := uint64(Varint)
+		if trimmed, ok := strings.CutSuffix(name, "i32"); ok {
+			name, wireType = trimmed, B32
+		} else if trimmed, ok := strings.CutSuffix(name, "i64"); ok {
+			name, wireType = trimmed, B64
+		}
+		id, err := strconv.ParseUint(name, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid field ID %q", name)
+		}
+		p.skipSpace()
+		if p.pos >= len(p.text) || p.text[p.pos] != ':' {
+			return nil, fmt.Errorf("Expected : after field %d", id)
+		}
+		p.pos++
+		p.skipSpace()
+		f, err := p.parseValue(id, wireType)
+		if err != nil {
+			return nil, err
+		}
+		addField(msg, id, f)
+	}
+}
+
+func (p *protoscopeParser) parseValue(id, wireType uint64) (Field, error) {
+	rest := p.text[p.pos:]
+	switch {
+	case wireType == Varint && (strings.HasPrefix(rest, "{") || strings.HasPrefix(rest, "!{")):
+		f := Field{wireType: LengthDelim}
+		if rest[0] == '!' {
+			p.pos++
+			f.wireType = SGroup
+		}
+		p.pos++
+		subMsg, err := p.parseMessage(true)
+		if err != nil {
+			return Field{}, err
+		}
+		f.message = subMsg
+		return f, nil
+	case wireType == Varint && strings.HasPrefix(rest, `"`):
+		b, err := p.parseQuoted()
+		if err != nil {
+			return Field{}, err
+		}
+		if utf8.Valid(b) {
+			s := string(b)
+			return Field{string: &s, wireType: LengthDelim}, nil
+		}
+		return Field{bytes: &b, wireType: LengthDelim}, nil
+	}
+	tok := p.token()
+	if tok == "" {
+		return Field{}, fmt.Errorf("Expected a value for field %d", id)
+	}
+	if wireType == Varint && (strings.HasPrefix(tok, "0x") || strings.HasPrefix(tok, "0X")) {
+		b, err := hex.DecodeString(tok[2:])
+		if err != nil {
+			return Field{}, fmt.Errorf("Invalid hex bytes %q for field %d", tok, id)
+		}
+		return Field{bytes: &b, wireType: LengthDelim}, nil
+	}
+	x, err := parseTextInt(tok)
+	if err != nil && wireType != Varint {
+		x, err = parseFixedFloat(tok, wireType)
+	}
+	if err != nil {
+		return Field{}, fmt.Errorf("Invalid value %q for field %d", tok, id)
+	}
+	if wireType == B32 {
+		if x > math.MaxUint32 && (int64(x) >= 0 || int64(x) < math.MinInt32) {
+			return Field{}, fmt.Errorf("Value %s does not fit in fixed32 field %d", tok, id)
+		}
+		x = uint64(uint32(x))
+	}
+	return Field{numeric: &x, wireType: wireType}, nil
+}
+
+// parseFixedFloat parses a float literal into the bits of a fixed32 or
+// fixed64 value
+func parseFixedFloat(tok string, wireType uint64) (uint64, error) {
+	if wireType == B32 {
+		v, err := strconv.ParseFloat(tok, 32)
+		return uint64(math.Float32bits(float32(v))), err
+	}
+	v, err := strconv.ParseFloat(tok, 64)
+	return math.Float64bits(v), err
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	reflectionv1 "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+type DescriptorResolver interface {
+	FileContainingSymbol(symbol string) (*descriptorpb.FileDescriptorProto, error)
+	FileByFilename(filename string) (*descriptorpb.FileDescriptorProto, error)
+}
+
+// ReflectionResolver fetches descriptors from a server that registers the
+// grpc.reflection.v1 service, caching every file it receives
+type ReflectionResolver struct {
+	// fetch asks the server for the file defining symbol, or for filename,
+	// and returns it serialized, followed by any of its dependencies
+	fetch func(symbol, filename string) ([][]byte, error)
+	// files maps filenames and symbols to *descriptorpb.FileDescriptorProto
+	files   sync.Map
+	symbols sync.Map
+}
+
+// ReflectionResolverV1alpha is a ReflectionResolver for servers that only
+// register the older grpc.reflection.v1alpha service
+type ReflectionResolverV1alpha struct {
+	ReflectionResolver
+}
+
+func NewReflectionResolver(conn *grpc.ClientConn) *ReflectionResolver {
+	return &ReflectionResolver{fetch: reflectionFetch(conn, reflectionv1.ServerReflection_ServerReflectionInfo_FullMethodName)}
+}
+
+func NewReflectionResolverV1alpha(conn *grpc.ClientConn) *ReflectionResolverV1alpha {
+	// v1alpha messages are identical to v1 on the wire, so only the method
+	// differs
+	return &ReflectionResolverV1alpha{ReflectionResolver{
+		fetch: reflectionFetch(conn, "/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo"),
+	}}
+}
+
+// reflectionFetch returns a ReflectionResolver fetch function that makes
+// one ServerReflectionInfo call to method per request
+func reflectionFetch(conn *grpc.ClientConn, method string) func(symbol, filename string) ([][]byte, error) {
+	return func(symbol, filename string) ([][]byte, error) {
+		req := &reflectionv1.ServerReflectionRequest{}
+		if symbol != "" {
+			req.MessageRequest = &reflectionv1.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: symbol}
+		} else {
+			req.MessageRequest = &reflectionv1.ServerReflectionRequest_FileByFilename{FileByFilename: filename}
+		}
+		// cancelling ends the stream, which CloseSend alone would leave open
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}, method)
+		if err != nil {
+			return nil, err
+		}
+		if err := stream.SendMsg(req); err != nil {
+			return nil, err
+		}
+		resp := &reflectionv1.ServerReflectionResponse{}
+		if err := stream.RecvMsg(resp); err != nil {
+			return nil, err
+		}
+		if e := resp.GetErrorResponse(); e != nil {
+			return nil, fmt.Errorf("Reflection error %d: %s", e.GetErrorCode(), e.GetErrorMessage())
+		}
+		return resp.GetFileDescriptorResponse().GetFileDescriptorProto(), nil
+	}
+}
+
+func (r *ReflectionResolver) FileContainingSymbol(symbol string) (*descriptorpb.FileDescriptorProto, error) {
+	symbol = strings.TrimPrefix(symbol, ".")
+	if file, ok := r.symbols.Load(symbol); ok {
+		return file.(*descriptorpb.FileDescriptorProto), nil
+	}
+	files, err := r.load(symbol, "")
+	if err != nil {
+		return nil, fmt.Errorf("Failed to resolve symbol %s: %v", symbol, err)
+	}
+	return files[0], nil
+}
+
+func (r *ReflectionResolver) FileByFilename(filename string) (*descriptorpb.FileDescriptorProto, error) {
+	if file, ok := r.files.Load(filename); ok {
+		return file.(*descriptorpb.FileDescriptorProto), nil
+	}
+	files, err := r.load("", filename)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to resolve file %s: %v", filename, err)
+	}
+	return files[0], nil
+}
+
+// load fetches and caches a file along with its dependencies, indexing
+// every symbol they define
+func (r *ReflectionResolver) load(symbol, filename string) ([]*descriptorpb.FileDescriptorProto, error) {
+	raw, err := r.fetch(symbol, filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("Server returned no descriptors")
+	}
+	files := make([]*descriptorpb.FileDescriptorProto, len(raw))
+	for i, b := range raw {
+		file := &descriptorpb.FileDescriptorProto{}
+		if err := proto.Unmarshal(b, file); err != nil {
+			return nil, fmt.Errorf("Invalid file descriptor: %v", err)
+		}
+		files[i] = file
+		r.files.Store(file.GetName(), file)
+		for _, name := range fileSymbols(file) {
+			r.symbols.Store(name, file)
+		}
+	}
+	if symbol != "" {
+		// the first file is the one defining symbol, even if it is a
+		// field or method name that fileSymbols doesn't index
+		r.symbols.Store(symbol, files[0])
+	}
+	return files, nil
+}
+
+func fileSymbols(file *descriptorpb.FileDescriptorProto) []string {
+	prefix := ""
+	if file.GetPackage() != "" {
+		prefix = file.GetPackage() + "."
+	}
+	names := []string{}
+	var addMessages func(prefix string, msgs []*descriptorpb.DescriptorProto)
+	addMessages = func(prefix string, msgs []*descriptorpb.DescriptorProto) {
+		for _, m := range msgs {
+			name := prefix + m.GetName()
+			names = append(names, name)
+			for _, e := range m.GetEnumType() {
+				names = append(names, name+"."+e.GetName())
+			}
+			addMessages(name+".", m.GetNestedType())
+		}
+	}
+	addMessages(prefix, file.GetMessageType())
+	for _, e := range file.GetEnumType() {
+		names = append(names, prefix+e.GetName())
+	}
+	for _, s := range file.GetService() {
+		names = append(names, prefix+s.GetName())
+	}
+	return names
+}
+
+// findMessageType returns the descriptor for a fully-qualified message name
+// defined in file
+func findMessageType(file *descriptorpb.FileDescriptorProto, name string) *descriptorpb.DescriptorProto {
+	name = strings.TrimPrefix(name, ".")
+	if file.GetPackage() != "" {
+		if !strings.HasPrefix(name, file.GetPackage()+".") {
+			return nil
+		}
+		name = strings.TrimPrefix(name, file.GetPackage()+".")
+	}
+	msgs := file.GetMessageType()
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		var found *descriptorpb.DescriptorProto
+		for _, m := range msgs {
+			if m.GetName() == part {
+				found = m
+				break
+			}
+		}
+		if found == nil {
+			return nil
+		}
+		if i == len(parts)-1 {
+			return found
+		}
+		msgs = found.GetNestedType()
+	}
+	return nil
+}
+
+var descriptorFieldTypes = map[descriptorpb.FieldDescriptorProto_Type]FieldType{
+	descriptorpb.FieldDescriptorProto_TYPE_DOUBLE:   TypeDouble,
+	descriptorpb.FieldDescriptorProto_TYPE_FLOAT:    TypeFloat,
+	descriptorpb.FieldDescriptorProto_TYPE_INT64:    TypeInt64,
+	descriptorpb.FieldDescriptorProto_TYPE_UINT64:   TypeUint64,
+	descriptorpb.FieldDescriptorProto_TYPE_INT32:    TypeInt32,
+	descriptorpb.FieldDescriptorProto_TYPE_FIXED64:  TypeFixed64,
+	descriptorpb.FieldDescriptorProto_TYPE_FIXED32:  TypeFixed32,
+	descriptorpb.FieldDescriptorProto_TYPE_BOOL:     TypeBool,
+	descriptorpb.FieldDescriptorProto_TYPE_STRING:   TypeString,
+	descriptorpb.FieldDescriptorProto_TYPE_GROUP:    TypeMessage,
+	descriptorpb.FieldDescriptorProto_TYPE_MESSAGE:  TypeMessage,
+	descriptorpb.FieldDescriptorProto_TYPE_BYTES:    TypeBytes,
+	descriptorpb.FieldDescriptorProto_TYPE_UINT32:   TypeUint32,
+	descriptorpb.FieldDescriptorProto_TYPE_ENUM:     TypeInt32,
+	descriptorpb.FieldDescriptorProto_TYPE_SFIXED32: TypeSfixed32,
+	descriptorpb.FieldDescriptorProto_TYPE_SFIXED64: TypeSfixed64,
+	descriptorpb.FieldDescriptorProto_TYPE_SINT32:   TypeSint32,
+	descriptorpb.FieldDescriptorProto_TYPE_SINT64:   TypeSint64,
+}
+
+// RenderWithDescriptor renders m as the named message type, keying fields
+// by name and interpreting values by their declared types. Fields missing
+// from the descriptor keep their numeric IDs.
+func RenderWithDescriptor(m *Message, r DescriptorResolver, messageName string) (string, error) {
+	var sb strings.Builder
+	if err := renderWithDescriptor(&sb, m, r, messageName); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+func renderWithDescriptor(sb *strings.Builder, m *Message, r DescriptorResolver, messageName string) error {
+	file, err := r.FileContainingSymbol(messageName)
+	if err != nil {
+		return err
+	}
+	desc := findMessageType(file, messageName)
+	if desc == nil {
+		return fmt.Errorf("Message type %s not found in %s", messageName, file.GetName())
+	}
+	fieldDescs := map[uint64]*descriptorpb.FieldDescriptorProto{}
+	for _, fd := range desc.GetField() {
+		fieldDescs[uint64(fd.GetNumber())] = fd
+	}
+	sb.WriteString("{")
+	if m != nil {
+		for i, id := range m.FieldIDs() {
+			if i > 0 {
+				sb.WriteString(",")
+			}
+			fd := fieldDescs[id]
+			key := fmt.Sprint(id)
+			if fd != nil {
+				key = fd.GetName()
+			}
+			fields := (*m)[id]
+			sb.WriteString(quoteString(key) + ":")
+			repeated := len(fields) > 1 || fd.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+			if repeated {
+				sb.WriteString("[")
+			}
+			for j, f := range fields {
+				if j > 0 {
+					sb.WriteString(",")
+				}
+				if err := renderFieldWithDescriptor(sb, id, f, fd, r); err != nil {
+					return err
+				}
+			}
+			if repeated {
+				sb.WriteString("]")
+			}
+		}
+	}
+	sb.WriteString("}")
+	return nil
+}
+
+func renderFieldWithDescriptor(sb *strings.Builder, id uint64, f Field, fd *descriptorpb.FieldDescriptorProto, r DescriptorResolver) error {
+	if fd == nil {
+		sb.WriteString(RenderFieldWithOptions(id, f, RenderOptions{Sorted: true}))
+		return nil
+	}
+	t := descriptorFieldTypes[fd.GetType()]
+	switch {
+	case t == TypeMessage && f.message != nil:
+		return renderWithDescriptor(sb, f.message, r, fd.GetTypeName())
+	case t == TypeString || t == TypeBytes:
+		// the value may have been misread as a sub-message
+		if f.message != nil {
+			b, err := f.messageBytes()
+			if err != nil {
+				return err
+			}
+			f = Field{bytes: &b, wireType: LengthDelim}
+		}
+		if t == TypeString && f.bytes != nil {
+			s := string(*f.bytes)
+			f = Field{string: &s, wireType: LengthDelim}
+		}
+		if t == TypeBytes && f.string != nil {
+			b := []byte(*f.string)
+			f = Field{bytes: &b, wireType: LengthDelim}
+		}
+		sb.WriteString(RenderFieldWithOptions(id, f, RenderOptions{BytesFormat: BytesBase64}))
+	case f.numeric != nil:
+		sb.WriteString(renderNumericAs(*f.numeric, t, RenderOptions{JSONConformantFloats: true}))
+	default:
+		sb.WriteString(RenderFieldWithOptions(id, f, RenderOptions{Sorted: true, BytesFormat: BytesBase64}))
+	}
+	return nil
+}
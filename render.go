@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type BytesFormat int
+
+const (
+	BytesHex BytesFormat = iota
+	BytesBase64
+	BytesRawString
+	BytesGoLiteral
+)
+
+func (f BytesFormat) String() string {
+	switch f {
+	case BytesHex:
+		return "hex"
+	case BytesBase64:
+		return "base64"
+	case BytesRawString:
+		return "raw"
+	case BytesGoLiteral:
+		return "go"
+	default:
+		return fmt.Sprintf("BytesFormat(%d)", int(f))
+	}
+}
+
+type RenderOptions struct {
+	ExtensionFields map[uint64]string
+	// FieldNames renders the listed fields with a name as the key
+	// instead of the field ID
+	FieldNames  map[uint64]string
+	UUIDFields  []uint64
+	AutoUUID    bool
+	BytesFormat BytesFormat
+	// FloatFields and DoubleFields reinterpret the bits of fixed32 and
+	// fixed64 fields as IEEE 754 floats
+	FloatFields  []uint64
+	DoubleFields []uint64
+	// JSONConformantFloats renders NaN, infinities and negative zero as
+	// strings, as the proto3 JSON mapping requires
+	JSONConformantFloats bool
+	// Sorted renders fields in ascending field ID order
+	Sorted bool
+	// Separator is written after each frame in multi-frame output, and
+	// FramePrefix (a format string given the 1-based frame number and,
+	// via %[2]d, the frame size in bytes) is written before each frame
+	Separator   string
+	FramePrefix string
+	// IndexRepeated renders repeated fields as "1[0]", "1[1]", ... keys
+	// instead of a JSON array
+	IndexRepeated bool
+	// TypeHints renders numeric fields as the given scalar type
+	TypeHints map[uint64]FieldType
+	// BoolFields renders the listed varint fields as true or false
+	BoolFields []uint64
+	// ShowByteRanges adds each value's byte range in the input to its key,
+	// as in "1[2:7]", for messages from ParseProtoAnnotated; repeated
+	// values then get a key each
+	ShowByteRanges bool
+	// HumanReadable renders byte counts, such as the frame size and
+	// histogram buckets, with binary prefixes, or decimal ones if SI is
+	// also set
+	HumanReadable bool
+	SI            bool
+	// Indent, when set, renders one field per line with nested values
+	// indented by this string
+	Indent string
+	// AutoTimestamp appends the UTC date to varint fields whose values
+	// look like Unix timestamps in seconds, and TimestampFields does so
+	// for the listed numeric fields whatever their value
+	AutoTimestamp   bool
+	TimestampFields []uint64
+}
+
+// Unix timestamps in seconds from 2001 to 2286 have 10 digits, the range
+// AutoTimestamp treats as timestamps
+const (
+	minAutoTimestamp = 1000000000
+	maxAutoTimestamp = 9999999999
+)
+
+func DefaultRenderOptions() RenderOptions {
+	return RenderOptions{
+		Separator: "\n",
+	}
+}
+
+func Render(m *Message) string {
+	return RenderWithOptions(m, RenderOptions{})
+}
+
+func RenderSorted(m *Message) string {
+	return RenderWithOptions(m, RenderOptions{Sorted: true})
+}
+
+func RenderWithOptions(m *Message, opts RenderOptions) string {
+	var sb strings.Builder
+	WriteMessage(&sb, m, opts)
+	return sb.String()
+}
+
+func RenderPretty(m *Message) string {
+	var sb strings.Builder
+	WritePretty(&sb, m)
+	return sb.String()
+}
+
+func RenderFrames(msgs []*Message, opts RenderOptions) string {
+	var sb strings.Builder
+	for i, m := range msgs {
+		sb.WriteString(opts.framePrefix(i+1, EncodedSize(m)))
+		sb.WriteString(RenderWithOptions(m, opts))
+		sb.WriteString(opts.Separator)
+	}
+	return sb.String()
+}
+
+func RenderField(f Field) string {
+	return RenderFieldWithOptions(0, f, RenderOptions{})
+}
+
+func RenderFieldWithOptions(id uint64, f Field, opts RenderOptions) string {
+	if f.numeric != nil {
+		if t, ok := opts.TypeHints[id]; ok {
+			return renderNumericAs(*f.numeric, t, opts)
+		}
+		if b, ok := f.Bool(); ok && containsID(opts.BoolFields, id) {
+			return strconv.FormatBool(b)
+		}
+		if containsID(opts.FloatFields, id) {
+			return renderFloat(float64(math.Float32frombits(uint32(*f.numeric))), 32, opts)
+		}
+		if containsID(opts.DoubleFields, id) {
+			return renderFloat(math.Float64frombits(*f.numeric), 64, opts)
+		}
+		x := *f.numeric
+		if containsID(opts.TimestampFields, id) ||
+			(opts.AutoTimestamp && f.wireType == Varint && x >= minAutoTimestamp && x <= maxAutoTimestamp) {
+			return fmt.Sprintf("\"%d (%s)\"", x, time.Unix(int64(x), 0).UTC().Format("2006-01-02 15:04:05 MST"))
+		}
+		return fmt.Sprintf("%d", x)
+	}
+	if f.string != nil {
+		if containsID(opts.UUIDFields, id) && len(*f.string) == 16 {
+			return fmt.Sprintf("\"%s\"", formatUUID([]byte(*f.string)))
+		}
+		return quoteString(*f.string)
+	}
+	if f.message != nil {
+		return RenderWithOptions(f.message, opts)
+	}
+	if f.bytes != nil {
+		if len(*f.bytes) == 16 && (opts.AutoUUID || containsID(opts.UUIDFields, id)) {
+			return fmt.Sprintf("\"%s\"", formatUUID(*f.bytes))
+		}
+		return renderBytes(*f.bytes, opts.BytesFormat)
+	}
+	return ""
+}
+
+func renderFloat(v float64, bitSize int, opts RenderOptions) string {
+	if opts.JSONConformantFloats {
+		switch {
+		case math.IsNaN(v):
+			return "\"NaN\""
+		case math.IsInf(v, 1):
+			return "\"Infinity\""
+		case math.IsInf(v, -1):
+			return "\"-Infinity\""
+		case v == 0 && math.Signbit(v):
+			return "\"-0\""
+		}
+	}
+	return strconv.FormatFloat(v, 'g', -1, bitSize)
+}
+
+func renderBytes(b []byte, format BytesFormat) string {
+	switch format {
+	case BytesBase64:
+		return fmt.Sprintf("\"%s\"", base64.StdEncoding.EncodeToString(b))
+	case BytesRawString:
+		return strconv.Quote(string(b))
+	case BytesGoLiteral:
+		var sb strings.Builder
+		sb.WriteByte('"')
+		for _, c := range b {
+			fmt.Fprintf(&sb, "\\x%02x", c)
+		}
+		sb.WriteByte('"')
+		return sb.String()
+	default:
+		return fmt.Sprintf("%x", b)
+	}
+}
+
+func (opts RenderOptions) framePrefix(n, size int) string {
+	if opts.FramePrefix == "" {
+		return ""
+	}
+	verbs := strings.Count(opts.FramePrefix, "%") - 2*strings.Count(opts.FramePrefix, "%%")
+	switch {
+	case strings.Contains(opts.FramePrefix, "%[") || verbs >= 2:
+		return fmt.Sprintf(opts.FramePrefix, n, byteCount{n: size, human: opts.HumanReadable, si: opts.SI})
+	case verbs == 1:
+		return fmt.Sprintf(opts.FramePrefix, n)
+	default:
+		return strings.ReplaceAll(opts.FramePrefix, "%%", "%")
+	}
+}
+
+func (opts RenderOptions) fieldIDs(m Message) []uint64 {
+	if opts.Sorted {
+		return m.FieldIDs()
+	}
+	ids := make([]uint64, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (opts RenderOptions) fieldKey(id uint64) string {
+	if name, ok := opts.ExtensionFields[id]; ok {
+		return fmt.Sprintf("[%s]", name)
+	}
+	if name, ok := opts.FieldNames[id]; ok {
+		return name
+	}
+	return strconv.FormatUint(id, 10)
+}
+
+func quoteString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func formatUUID(b []byte) string {
+	h := hex.EncodeToString(b)
+	return fmt.Sprintf("%s-%s-%s-%s-%s", h[0:8], h[8:12], h[12:16], h[16:20], h[20:32])
+}
+
+func containsID(ids []uint64, id uint64) bool {
+	for _, x := range ids {
+		if x == id {
+			return true
+		}
+	}
+	return false
+}
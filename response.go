@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// grpcWebTrailerFlag marks a gRPC-Web frame whose payload is the trailer
+// block rather than a message
+const grpcWebTrailerFlag = 0x80
+
+type GrpcResponse struct {
+	Messages   []*Message
+	Trailer    *GrpcTrailer
+	RawTrailer []byte
+}
+
+func ParseGrpcResponse(data []byte, opts ...ParseOption) (*GrpcResponse, error) {
+	resp := &GrpcResponse{
+		Messages: []*Message{},
+	}
+	for len(data) > 0 {
+		frame := len(resp.Messages) + 1
+		if len(data) >= 5 && data[0]&grpcWebTrailerFlag != 0 {
+			size := binary.BigEndian.Uint32(data[1:5])
+			if uint64(len(data)-5) < uint64(size) {
+				return nil, fmt.Errorf("Frame %d: Incomplete gRPC-Web trailer frame, wanted %d bytes but only found %d", frame, size, len(data)-5)
+			}
+			if uint64(len(data)-5) > uint64(size) {
+				return nil, fmt.Errorf("Frame %d: Unexpected data after gRPC-Web trailer frame", frame)
+			}
+			resp.RawTrailer = data[5:]
+			trailer, err := ParseGrpcTrailer(resp.RawTrailer)
+			if err != nil {
+				return nil, fmt.Errorf("Frame %d: %v", frame, err)
+			}
+			resp.Trailer = trailer
+			return resp, nil
+		}
+		msg, n, err := ParseGrpc(data, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("Frame %d: %v", frame, err)
+		}
+		resp.Messages = append(resp.Messages, msg)
+		data = data[n:]
+	}
+	return resp, nil
+}
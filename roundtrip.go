@@ -0,0 +1,29 @@
+package main
+
+import "fmt"
+
+// ValidateRoundTrip checks that parsing data, encoding the result and
+// parsing that again gives the same message. The bytes themselves may
+// differ, since Encode writes minimal varints and orders fields by ID.
+func ValidateRoundTrip(data []byte) error {
+	return ValidateRoundTripWithOptions(data, DefaultParseOptions())
+}
+
+func ValidateRoundTripWithOptions(data []byte, opts ParseOptions) error {
+	original, _, err := ParseProtoWithOptions(data, opts)
+	if err != nil {
+		return err
+	}
+	encoded, err := Encode(original)
+	if err != nil {
+		return fmt.Errorf("Could not re-encode the message: %v", err)
+	}
+	reparsed, _, err := ParseProtoWithOptions(encoded, opts)
+	if err != nil {
+		return fmt.Errorf("Could not parse the re-encoded message: %v\noriginal:   %x\nre-encoded: %x", err, data, encoded)
+	}
+	if !EqualStrict(original, reparsed) {
+		return fmt.Errorf("Re-encoded message differs:\n%s\noriginal:   %x\nre-encoded: %x", RenderDiff(Diff(original, reparsed)), data, encoded)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import "sync"
+
+// SafeMessage is a Message that may be used from multiple goroutines
+type SafeMessage struct {
+	mu  sync.RWMutex
+	msg Message
+}
+
+func NewSafeMessage() *SafeMessage {
+	return &SafeMessage{msg: NewMessage()}
+}
+
+func (s *SafeMessage) SafeAddField(id uint64, field Field) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.msg.Add(id, field)
+}
+
+// SafeGet returns a copy of the values of a field
+func (s *SafeMessage) SafeGet(id uint64) []Field {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Field(nil), s.msg[id]...)
+}
+
+func (s *SafeMessage) SafeHas(id uint64) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.msg[id]
+	return ok
+}
+
+// Message returns a copy of the fields added so far
+func (s *SafeMessage) Message() *Message {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	msg := NewMessage()
+	for id, fields := range s.msg {
+		msg[id] = append([]Field(nil), fields...)
+	}
+	return &msg
+}
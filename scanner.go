@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+type GrpcScanner struct {
+	r      io.Reader
+	opts   ParseOptions
+	msg    *Message
+	size   int
+	err    error
+	frame  int
+	offset int64
+	next   int64
+}
+
+func NewGrpcScanner(r io.Reader, opts ...ParseOption) *GrpcScanner {
+	return &GrpcScanner{r: r, opts: applyOptions(opts)}
+}
+
+func (s *GrpcScanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+	s.msg = nil
+	header := make([]byte, 5)
+	n, err := io.ReadFull(s.r, header)
+	if n > 0 {
+		s.frame++
+		s.offset = s.next
+	}
+	if err != nil {
+		if err != io.EOF {
+			s.err = fmt.Errorf("Incomplete gRPC frame header: %v", err)
+		}
+		return false
+	}
+	size, err := parseGrpcHeader(header)
+	if err != nil {
+		s.err = err
+		return false
+	}
+	if s.opts.MaxSize > 0 && uint64(size) > uint64(s.opts.MaxSize) {
+		s.err = &limitError{limit: "size", max: s.opts.MaxSize}
+		return false
+	}
+	payload := make([]byte, size)
+	if n, err := io.ReadFull(s.r, payload); err != nil {
+		s.err = fmt.Errorf("Incomplete gRPC frame, wanted %d bytes but only found %d", size, n)
+		return false
+	}
+	msg, _, err := ParseProtoWithOptions(payload, s.opts)
+	if err != nil {
+		s.err = err
+		return false
+	}
+	s.msg = msg
+	s.size = int(size)
+	s.next += 5 + int64(size)
+	return true
+}
+
+func (s *GrpcScanner) Message() *Message {
+	return s.msg
+}
+
+func (s *GrpcScanner) Err() error {
+	return s.err
+}
+
+// FrameNumber is the 1-based number of the current frame, or of the frame
+// that failed to scan
+func (s *GrpcScanner) FrameNumber() int {
+	return s.frame
+}
+
+// ByteOffset is the position of the current frame's header in the stream
+func (s *GrpcScanner) ByteOffset() int64 {
+	return s.offset
+}
+
+// watchFramePrefix labels each frame WatchGrpc renders when opts has no
+// FramePrefix of its own
+const watchFramePrefix = "Frame %d: "
+
+// WatchGrpc renders each frame read from r as soon as it arrives, labelled
+// with its frame number
+func WatchGrpc(r io.Reader, w io.Writer, opts RenderOptions, popts ParseOptions) error {
+	if opts.FramePrefix == "" {
+		opts.FramePrefix = watchFramePrefix
+	}
+	s := &GrpcScanner{r: r, opts: popts}
+	for s.Scan() {
+		out := opts.framePrefix(s.FrameNumber(), s.size) + RenderWithOptions(s.Message(), opts) + opts.Separator
+		if _, err := io.WriteString(w, out); err != nil {
+			return err
+		}
+	}
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("Frame %d: %v", s.FrameNumber(), err)
+	}
+	return nil
+}
+
+// TeeProto reads one gRPC frame from r, forwarding its bytes to w as they
+// are read, and parses it. It stops at the first write error, so w is never
+// left with bytes past the point of failure. Compressed frames are still
+// forwarded in full but not parsed.
+func TeeProto(r io.Reader, w io.Writer, opts ParseOptions) (*Message, error) {
+	tee := io.TeeReader(r, w)
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(tee, header); err != nil {
+		return nil, fmt.Errorf("Failed to forward gRPC frame header: %v", err)
+	}
+	compressed, size, err := readGrpcHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	payload := make([]byte, size)
+	if n, err := io.ReadFull(tee, payload); err != nil {
+		return nil, fmt.Errorf("Failed to forward gRPC frame, wanted %d bytes but only forwarded %d: %v", size, n, err)
+	}
+	if compressed {
+		return nil, fmt.Errorf("Compressed gRPC frames are not supported")
+	}
+	msg, _, err := ParseProtoWithOptions(payload, opts)
+	return msg, err
+}
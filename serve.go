@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const servePage = `<!DOCTYPE html>
+<html>
+<head><title>grpc-parse</title></head>
+<body>
+<h1>grpc-parse</h1>
+<p>Paste a gRPC frame or protobuf message as hex or base64.</p>
+<textarea id="input" rows="10" cols="80"></textarea><br>
+<select id="format">
+<option value="json">JSON</option>
+<option value="text">Text format</option>
+<option value="hex">Hex bytes</option>
+</select>
+<button onclick="parse()">Parse</button>
+<pre id="output"></pre>
+<script>
+function decode(s) {
+  s = s.replace(/\s+/g, "");
+  if (/^([0-9a-fA-F]{2})*$/.test(s)) {
+    return new Uint8Array((s.match(/../g) || []).map(h => parseInt(h, 16)));
+  }
+  return Uint8Array.from(atob(s), c => c.charCodeAt(0));
+}
+async function parse() {
+  const out = document.getElementById("output");
+  let body;
+  try {
+    body = decode(document.getElementById("input").value);
+  } catch (e) {
+    out.textContent = "Input is neither hex nor base64";
+    return;
+  }
+  const format = document.getElementById("format").value;
+  const resp = await fetch("/parse?format=" + format, {
+    method: "POST",
+    headers: {"Content-Type": "application/octet-stream"},
+    body: body,
+  });
+  out.textContent = await resp.text();
+}
+</script>
+</body>
+</html>
+`
+
+// ParseHandler serves an HTML page at / for pasting hex or base64 input and
+// parses POSTs to /parse. A body sent as application/grpc+proto must be
+// gRPC frames; an application/octet-stream body may be in any encoding
+// DetectEncoding recognizes. The format query parameter picks json (the
+// default), text or hex output and depth limits sub-message nesting, up to
+// opts.MaxDepth if it is set.
+func ParseHandler(opts ParseOptions) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			serveError(w, http.StatusMethodNotAllowed, fmt.Errorf("Method %s is not allowed", r.Method))
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, servePage)
+	})
+	mux.HandleFunc("/parse", func(w http.ResponseWriter, r *http.Request) {
+		serveParse(w, r, opts)
+	})
+	return mux
+}
+
+func serveParse(w http.ResponseWriter, r *http.Request, opts ParseOptions) {
+	if r.Method != http.MethodPost {
+		serveError(w, http.StatusMethodNotAllowed, fmt.Errorf("Method %s is not allowed, POST the message to parse", r.Method))
+		return
+	}
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType != "application/octet-stream" && contentType != "application/grpc+proto" {
+		serveError(w, http.StatusUnsupportedMediaType, fmt.Errorf("Unsupported content type %q, expected application/octet-stream or application/grpc+proto", contentType))
+		return
+	}
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "text" && format != "hex" {
+		serveError(w, http.StatusBadRequest, fmt.Errorf("Unknown format %q, expected json, text or hex", format))
+		return
+	}
+	if depth := query.Get("depth"); depth != "" {
+		n, err := strconv.Atoi(depth)
+		if err != nil || n < 0 {
+			serveError(w, http.StatusBadRequest, fmt.Errorf("Invalid depth %q", depth))
+			return
+		}
+		if opts.MaxDepth > 0 {
+			// the query can only tighten the server's limit
+			if n == 0 {
+				serveError(w, http.StatusBadRequest, fmt.Errorf("Invalid depth 0, the maximum depth is %d", opts.MaxDepth))
+				return
+			}
+			if n > opts.MaxDepth {
+				n = opts.MaxDepth
+			}
+		}
+		opts.MaxDepth = n
+	}
+	body := io.Reader(r.Body)
+	if opts.MaxSize > 0 {
+		body = http.MaxBytesReader(w, r.Body, int64(opts.MaxSize))
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		serveError(w, http.StatusBadRequest, fmt.Errorf("Failed to read request body: %v", err))
+		return
+	}
+	if len(data) == 0 {
+		serveError(w, http.StatusBadRequest, fmt.Errorf("Empty request body"))
+		return
+	}
+	if contentType == "application/octet-stream" {
+		if data, err = toGrpcFrames(data, opts); err != nil {
+			serveError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	msgs, err := ParseGrpcStreamWithOptions(data, opts)
+	if err != nil {
+		serveError(w, http.StatusBadRequest, err)
+		return
+	}
+	switch format {
+	case "json":
+		rendered := make([]string, len(msgs))
+		for i, m := range msgs {
+			rendered[i] = RenderJSON(m)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, "[%s]\n", strings.Join(rendered, ","))
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for i, m := range msgs {
+			if i > 0 {
+				io.WriteString(w, "---\n")
+			}
+			WriteText(w, m)
+		}
+	case "hex":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, m := range msgs {
+			fmt.Fprintln(w, RenderWithOptions(m, RenderOptions{Sorted: true, BytesFormat: BytesHex}))
+		}
+	}
+}
+
+func serveError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
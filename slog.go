@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// newLogger returns a slog logger writing to w for a --log-format, or nil
+// for the raw format, which renders messages without slog
+func newLogger(format string, w io.Writer) (*slog.Logger, error) {
+	switch format {
+	case "raw":
+		return nil, nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, nil)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, nil)), nil
+	default:
+		return nil, fmt.Errorf("Invalid --log-format %q, expected text, json or raw", format)
+	}
+}
+
+// MessageAttrs converts a message to slog attributes keyed by field ID,
+// with sub-messages as groups and repeated values keyed "1[0]", "1[1]"...
+// like RenderOptions.IndexRepeated
+func MessageAttrs(m *Message) []slog.Attr {
+	attrs := []slog.Attr{}
+	if m == nil {
+		return attrs
+	}
+	for _, id := range m.FieldIDs() {
+		fields := (*m)[id]
+		for i, f := range fields {
+			key := fmt.Sprintf("%d", id)
+			if len(fields) > 1 {
+				key = fmt.Sprintf("%d[%d]", id, i)
+			}
+			attrs = append(attrs, slog.Attr{Key: key, Value: fieldValue(f)})
+		}
+	}
+	return attrs
+}
+
+func fieldValue(f Field) slog.Value {
+	switch {
+	case f.numeric != nil:
+		return slog.Uint64Value(*f.numeric)
+	case f.string != nil:
+		return slog.StringValue(*f.string)
+	case f.bytes != nil:
+		return slog.StringValue(hex.EncodeToString(*f.bytes))
+	case f.message != nil:
+		return slog.GroupValue(MessageAttrs(f.message)...)
+	default:
+		return slog.StringValue("")
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+type MessageStats struct {
+	// FieldCount is the number of distinct top-level field IDs; the other
+	// counts cover every nesting level
+	FieldCount  int
+	TotalFields int
+	MaxDepth    int
+	EncodedSize int
+	StringBytes int
+	BytesBytes  int
+}
+
+func ComputeStats(m *Message) MessageStats {
+	stats := MessageStats{}
+	if m == nil || len(*m) == 0 {
+		return stats
+	}
+	stats.FieldCount = len(*m)
+	stats.EncodedSize = EncodedSize(m)
+	collectStats(m, 1, &stats)
+	return stats
+}
+
+func collectStats(m *Message, depth int, stats *MessageStats) {
+	if depth > stats.MaxDepth {
+		stats.MaxDepth = depth
+	}
+	for _, fields := range *m {
+		stats.TotalFields += len(fields)
+		for _, f := range fields {
+			switch {
+			case f.string != nil:
+				stats.StringBytes += len(*f.string)
+			case f.bytes != nil:
+				stats.BytesBytes += len(*f.bytes)
+			case f.message != nil:
+				collectStats(f.message, depth+1, stats)
+			}
+		}
+	}
+}
+
+// FieldStats summarizes the values of one top-level field across a
+// collection of messages; Count is the number of values seen
+type FieldStats struct {
+	ID                     uint64
+	Count                  int
+	MinNumeric, MaxNumeric uint64
+	StringLengths          []int
+	UniqueStrings          map[string]int
+}
+
+func CollectStats(msgs []*Message) map[uint64]*FieldStats {
+	stats := map[uint64]*FieldStats{}
+	seenNumeric := map[uint64]bool{}
+	for _, m := range msgs {
+		if m == nil {
+			continue
+		}
+		for id, fields := range *m {
+			s, ok := stats[id]
+			if !ok {
+				s = &FieldStats{
+					ID:            id,
+					UniqueStrings: map[string]int{},
+				}
+				stats[id] = s
+			}
+			for _, f := range fields {
+				switch {
+				case f.numeric != nil:
+					if !seenNumeric[id] || s.MinNumeric > *f.numeric {
+						s.MinNumeric = *f.numeric
+					}
+					seenNumeric[id] = true
+					if s.MaxNumeric < *f.numeric {
+						s.MaxNumeric = *f.numeric
+					}
+				case f.string != nil:
+					s.StringLengths = append(s.StringLengths, len(*f.string))
+					s.UniqueStrings[*f.string]++
+				}
+				s.Count++
+			}
+		}
+	}
+	return stats
+}
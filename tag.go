@@ -0,0 +1,30 @@
+package main
+
+import "fmt"
+
+var WireTypeNames = map[uint64]string{
+	Varint:      "varint",
+	B64:         "fixed64",
+	LengthDelim: "length-delimited",
+	SGroup:      "start group",
+	EGroup:      "end group",
+	B32:         "fixed32",
+}
+
+// WireTypeName names a wire type, or returns "unknown(N)" for wire types
+// outside the protobuf spec
+func WireTypeName(typ uint64) string {
+	if name, ok := WireTypeNames[typ]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", typ)
+}
+
+// TagString describes a tag as in "field 3, type: length-delimited"
+func TagString(t *Tag) string {
+	return fmt.Sprintf("field %d, type: %s", t.fieldID, WireTypeName(t.typ))
+}
+
+func (t *Tag) String() string {
+	return TagString(t)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"text/template"
+)
+
+// MessageTemplate renders messages with a text/template. Besides the
+// built-in functions, templates can use:
+//
+//	fields N   the values of field N of the message being rendered
+//	first      the first value of a list of fields
+//	numeric, string, bytes, message
+//	           the value of a field, failing if it holds another kind
+//	render     a message rendered as in the default output
+//
+// so that {{fields 2 | first | bytes | printf "%x"}} shows field 2 in hex.
+// The message is also the template's dot.
+type MessageTemplate struct {
+	tmpl *template.Template
+	// mu guards current, the message the fields function reads
+	mu      sync.Mutex
+	current *Message
+}
+
+func NewMessageTemplate(text string) (*MessageTemplate, error) {
+	t := &MessageTemplate{}
+	funcs := template.FuncMap{
+		"fields": func(id uint64) []Field {
+			if t.current == nil {
+				return nil
+			}
+			return (*t.current)[id]
+		},
+		"first": func(fields []Field) (Field, error) {
+			if len(fields) == 0 {
+				return Field{}, fmt.Errorf("Field has no values")
+			}
+			return fields[0], nil
+		},
+		"numeric": func(f Field) (uint64, error) {
+			if f.numeric == nil {
+				return 0, fmt.Errorf("Field is not numeric")
+			}
+			return *f.numeric, nil
+		},
+		"string": func(f Field) (string, error) {
+			if f.string == nil {
+				return "", fmt.Errorf("Field is not a string")
+			}
+			return *f.string, nil
+		},
+		"bytes": func(f Field) ([]byte, error) {
+			if f.bytes == nil && f.string == nil {
+				return nil, fmt.Errorf("Field is not bytes")
+			}
+			return f.BytesUnsafe(), nil
+		},
+		"message": func(f Field) (*Message, error) {
+			if f.message == nil {
+				return nil, fmt.Errorf("Field is not a sub-message")
+			}
+			return f.message, nil
+		},
+		"render": RenderSorted,
+	}
+	tmpl, err := template.New("message").Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid template: %v", err)
+	}
+	t.tmpl = tmpl
+	return t, nil
+}
+
+func (t *MessageTemplate) Execute(w io.Writer, m *Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.current = m
+	return t.tmpl.Execute(w, m)
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// RenderText renders a message in proto text format, using numeric field
+// IDs as field names since there is no descriptor
+func RenderText(m *Message) string {
+	var sb strings.Builder
+	WriteText(&sb, m)
+	return sb.String()
+}
+
+// quoteText quotes a string or bytes value with text format escapes,
+// leaving UTF-8 sequences intact only for strings
+func quoteText(b []byte, utf8OK bool) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, c := range b {
+		switch {
+		case c == '"':
+			sb.WriteString(`\"`)
+		case c == '\\':
+			sb.WriteString(`\\`)
+		case c == '\n':
+			sb.WriteString(`\n`)
+		case c == '\r':
+			sb.WriteString(`\r`)
+		case c == '\t':
+			sb.WriteString(`\t`)
+		case c >= 0x20 && c < 0x7f, c >= 0x80 && utf8OK:
+			sb.WriteByte(c)
+		default:
+			fmt.Fprintf(&sb, `\%03o`, c)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// ParseProtoText parses proto text format where field names are numeric
+// field IDs, written either as "1" or "field_1"
+func ParseProtoText(text string) (*Message, error) {
+	p := &textParser{text: text, line: 1}
+	msg, err := p.parseMessage(false)
+	if err != nil {
+		return nil, fmt.Errorf("Line %d: %v", p.line, err)
+	}
+	return msg, nil
+}
+
+type textParser struct {
+	text string
+	pos  int
+	line int
+}
+
+// skipSpace skips whitespace, comments and the optional "," or ";" field
+// separators
+func (p *textParser) skipSpace() {
+	for p.pos < len(p.text) {
+		switch c := p.text[p.pos]; {
+		case c == '\n':
+			p.line++
+			p.pos++
+		case c == ' ' || c == '\t' || c == '\r' || c == ',' || c == ';':
+			p.pos++
+		case c == '#':
+			for p.pos < len(p.text) && p.text[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *textParser) parseMessage(nested bool) (*Message, error) {
+	msg := NewMessage()
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.text) {
+			if nested {
+				return nil, fmt.Errorf("Unclosed message, expected }")
+			}
+			return &msg, nil
+		}
+		if p.text[p.pos] == '}' {
+			if !nested {
+				return nil, fmt.Errorf("Unexpected }")
+			}
+			p.pos++
+			return &msg, nil
+		}
+		id, err := p.parseFieldID()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		colon := p.pos < len(p.text) && p.text[p.pos] == ':'
+		if colon {
+			p.pos++
+			p.skipSpace()
+		}
+		if p.pos < len(p.text) && p.text[p.pos] == '{' {
+			p.pos++
+			subMsg, err := p.parseMessage(true)
+			if err != nil {
+				return nil, err
+			}
+			addField(msg, id, Field{
+				message:  subMsg,
+				wireType: LengthDelim,
+			})
+			continue
+		}
+		if !colon {
+			return nil, fmt.Errorf("Expected : or { after field %d", id)
+		}
+		f, err := p.parseValue(id)
+		if err != nil {
+			return nil, err
+		}
+		addField(msg, id, f)
+	}
+}
+
+func (p *textParser) parseFieldID() (uint64, error) {
+	name := p.token()
+	if name == "" {
+		return 0, fmt.Errorf("Expected a field ID, found %q", p.text[p.pos:p.pos+1])
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(name, "field_"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid field ID %q, field names must be numeric field IDs", name)
+	}
+	return id, nil
+}
+
+// token consumes a run of identifier or number characters
+func (p *textParser) token() string {
+	start := p.pos
+	for p.pos < len(p.text) {
+		c := p.text[p.pos]
+		if c != '_' && c != '-' && c != '+' && c != '.' &&
+			(c < '0' || c > '9') && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') {
+			break
+		}
+		p.pos++
+	}
+	return p.text[start:p.pos]
+}
+
+func (p *textParser) parseValue(id uint64) (Field, error) {
+	if p.pos < len(p.text) && (p.text[p.pos] == '"' || p.text[p.pos] == '\'') {
+		b, err := p.parseQuoted()
+		if err != nil {
+			return Field{}, err
+		}
+		if utf8.Valid(b) {
+			s := string(b)
+			return Field{string: &s, wireType: LengthDelim}, nil
+		}
+		return Field{bytes: &b, wireType: LengthDelim}, nil
+	}
+	tok := p.token()
+	x, err := parseTextInt(tok)
+	if err != nil {
+		return Field{}, fmt.Errorf("Invalid value %q for field %d", tok, id)
+	}
+	return Field{numeric: &x, wireType: Varint}, nil
+}
+
+func parseTextInt(tok string) (uint64, error) {
+	switch tok {
+	case "true":
+		return 1, nil
+	case "false":
+		return 0, nil
+	}
+	if x, err := strconv.ParseUint(tok, 0, 64); err == nil {
+		return x, nil
+	}
+	x, err := strconv.ParseInt(tok, 0, 64)
+	return uint64(x), err
+}
+
+// parseQuoted parses a single- or double-quoted string with C-style escapes
+func (p *textParser) parseQuoted() ([]byte, error) {
+	quote := p.text[p.pos]
+	p.pos++
+	var b []byte
+	for {
+		if p.pos >= len(p.text) || p.text[p.pos] == '\n' {
+			return nil, fmt.Errorf("Unterminated string")
+		}
+		c := p.text[p.pos]
+		p.pos++
+		if c == quote {
+			return b, nil
+		}
+		if c != '\\' {
+			b = append(b, c)
+			continue
+		}
+		if p.pos >= len(p.text) {
+			return nil, fmt.Errorf("Unterminated string")
+		}
+		c = p.text[p.pos]
+		p.pos++
+		switch c {
+		case 'n':
+			b = append(b, '\n')
+		case 'r':
+			b = append(b, '\r')
+		case 't':
+			b = append(b, '\t')
+		case 'a':
+			b = append(b, '\a')
+		case 'b':
+			b = append(b, '\b')
+		case 'f':
+			b = append(b, '\f')
+		case 'v':
+			b = append(b, '\v')
+		case '"', '\'', '\\', '?':
+			b = append(b, c)
+		case 'x':
+			end := p.pos
+			for end < len(p.text) && end < p.pos+2 && isHexDigit(p.text[end]) {
+				end++
+			}
+			if end == p.pos {
+				return nil, fmt.Errorf("Invalid \\x escape")
+			}
+			x, _ := strconv.ParseUint(p.text[p.pos:end], 16, 8)
+			b = append(b, byte(x))
+			p.pos = end
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			end := p.pos - 1
+			for end < len(p.text) && end < p.pos+2 && p.text[end] >= '0' && p.text[end] <= '7' {
+				end++
+			}
+			x, err := strconv.ParseUint(p.text[p.pos-1:end], 8, 8)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid octal escape \\%s", p.text[p.pos-1:end])
+			}
+			b = append(b, byte(x))
+			p.pos = end
+		default:
+			return nil, fmt.Errorf("Invalid escape \\%c", c)
+		}
+	}
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+type (
+	GrpcStatus struct {
+		Code    int32
+		Message string
+		Details []*Message
+	}
+
+	GrpcTrailer struct {
+		Status        int
+		Message       string
+		StatusDetails *GrpcStatus
+		Headers       map[string]string
+	}
+)
+
+func ParseGrpcTrailer(data []byte) (*GrpcTrailer, error) {
+	trailer := &GrpcTrailer{
+		Headers: map[string]string{},
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("Invalid trailer line %q, expected key: value", line)
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		value := strings.TrimSpace(line[i+1:])
+		trailer.Headers[key] = value
+		switch key {
+		case "grpc-status":
+			status, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid grpc-status trailer %q", value)
+			}
+			trailer.Status = status
+		case "grpc-message":
+			msg, err := url.PathUnescape(value)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid grpc-message trailer: %v", err)
+			}
+			trailer.Message = msg
+		case "grpc-status-details-bin":
+			details, err := decodeBinaryHeader(value)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid grpc-status-details-bin trailer: %v", err)
+			}
+			status, err := ParseGrpcStatus(details)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid grpc-status-details-bin trailer: %v", err)
+			}
+			trailer.StatusDetails = status
+		}
+	}
+	if _, ok := trailer.Headers["grpc-status"]; !ok {
+		return nil, fmt.Errorf("Missing grpc-status trailer")
+	}
+	return trailer, nil
+}
+
+func ParseGrpcStatus(data []byte) (*GrpcStatus, error) {
+	msg, _, err := ParseProtoWithOptions(data, ParseOptions{
+		ForceString:  []uint64{2},
+		ForceMessage: []uint64{3},
+	})
+	if err != nil {
+		return nil, err
+	}
+	status := &GrpcStatus{}
+	for _, f := range (*msg)[1] {
+		if f.numeric == nil {
+			return nil, fmt.Errorf("Status code must be a varint")
+		}
+		status.Code = int32(*f.numeric)
+	}
+	for _, f := range (*msg)[2] {
+		if f.string == nil {
+			return nil, fmt.Errorf("Status message must be a string")
+		}
+		status.Message = *f.string
+	}
+	for _, f := range (*msg)[3] {
+		if f.message == nil {
+			return nil, fmt.Errorf("Status details must be messages")
+		}
+		status.Details = append(status.Details, f.message)
+	}
+	return status, nil
+}
+
+// decodeBinaryHeader decodes a -bin metadata value, which may use either
+// base64 alphabet and may omit padding
+func decodeBinaryHeader(value string) ([]byte, error) {
+	value = strings.TrimRight(value, "=")
+	if b, err := base64.RawURLEncoding.DecodeString(value); err == nil {
+		return b, nil
+	}
+	return base64.RawStdEncoding.DecodeString(value)
+}
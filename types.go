@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+type FieldType int
+
+const (
+	TypeVarint FieldType = iota
+	TypeInt32
+	TypeInt64
+	TypeUint32
+	TypeUint64
+	TypeSint32
+	TypeSint64
+	TypeBool
+	TypeFixed32
+	TypeSfixed32
+	TypeFloat
+	TypeFixed64
+	TypeSfixed64
+	TypeDouble
+	TypeString
+	TypeBytes
+	TypeMessage
+)
+
+var fieldTypeNames = []string{
+	TypeVarint:   "varint",
+	TypeInt32:    "int32",
+	TypeInt64:    "int64",
+	TypeUint32:   "uint32",
+	TypeUint64:   "uint64",
+	TypeSint32:   "sint32",
+	TypeSint64:   "sint64",
+	TypeBool:     "bool",
+	TypeFixed32:  "fixed32",
+	TypeSfixed32: "sfixed32",
+	TypeFloat:    "float",
+	TypeFixed64:  "fixed64",
+	TypeSfixed64: "sfixed64",
+	TypeDouble:   "double",
+	TypeString:   "string",
+	TypeBytes:    "bytes",
+	TypeMessage:  "message",
+}
+
+func (t FieldType) String() string {
+	if t >= 0 && int(t) < len(fieldTypeNames) {
+		return fieldTypeNames[t]
+	}
+	return fmt.Sprintf("FieldType(%d)", int(t))
+}
+
+func ParseFieldType(name string) (FieldType, error) {
+	for t, n := range fieldTypeNames {
+		if n == name {
+			return FieldType(t), nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown field type %q", name)
+}
+
+// wireType returns the wire type a field of this type is encoded with
+func (t FieldType) wireType() uint64 {
+	switch t {
+	case TypeFixed32, TypeSfixed32, TypeFloat:
+		return B32
+	case TypeFixed64, TypeSfixed64, TypeDouble:
+		return B64
+	case TypeString, TypeBytes, TypeMessage:
+		return LengthDelim
+	default:
+		return Varint
+	}
+}
+
+func renderNumericAs(x uint64, t FieldType, opts RenderOptions) string {
+	switch t {
+	case TypeInt32, TypeSfixed32:
+		return strconv.FormatInt(int64(int32(x)), 10)
+	case TypeInt64, TypeSfixed64:
+		return strconv.FormatInt(int64(x), 10)
+	case TypeUint32, TypeFixed32:
+		return strconv.FormatUint(uint64(uint32(x)), 10)
+	case TypeSint32:
+		return strconv.FormatInt(int64(int32(uint32(x)>>1)^-int32(x&1)), 10)
+	case TypeSint64:
+		return strconv.FormatInt(int64(x>>1)^-int64(x&1), 10)
+	case TypeBool:
+		return strconv.FormatBool(x != 0)
+	case TypeFloat:
+		return renderFloat(float64(math.Float32frombits(uint32(x))), 32, opts)
+	case TypeDouble:
+		return renderFloat(math.Float64frombits(x), 64, opts)
+	default:
+		return strconv.FormatUint(x, 10)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import "google.golang.org/protobuf/encoding/protowire"
+
+type (
+	// ParsedMessage is a message parsed against a schema by
+	// ParseProtoMessage
+	ParsedMessage struct {
+		Message       *Message
+		UnknownFields []UnknownField
+		// PresentFields marks the top-level field IDs that appeared on the
+		// wire, including unknown ones, when ParseOptions.TrackPresence is
+		// set; proto3 otherwise can't tell a zero value from an absent one
+		PresentFields map[uint64]bool
+	}
+
+	// UnknownField is a field outside the schema, kept exactly as it was
+	// encoded: Data is everything after the tag, including the length
+	// prefix of a length-delimited field and the end tag of a group
+	UnknownField struct {
+		ID       uint64
+		WireType uint64
+		Data     []byte
+	}
+)
+
+// ParseProtoMessage parses data like ParseProto, except that when
+// ParseOptions.Schema is set, top-level fields not in it, or not of a wire
+// type compatible with it, are set aside in UnknownFields instead of being
+// parsed.
+func ParseProtoMessage(data []byte, opts ...ParseOption) (*ParsedMessage, error) {
+	o := applyOptions(opts)
+	pm := &ParsedMessage{
+		UnknownFields: []UnknownField{},
+	}
+	if o.Schema != nil {
+		o.unknownFields = &pm.UnknownFields
+	}
+	msg, _, err := ParseProtoWithOptions(data, o)
+	if err != nil {
+		return nil, err
+	}
+	pm.Message = msg
+	if o.TrackPresence {
+		pm.PresentFields = map[uint64]bool{}
+		for id := range *msg {
+			pm.PresentFields[id] = true
+		}
+		for _, f := range pm.UnknownFields {
+			pm.PresentFields[f.ID] = true
+		}
+	}
+	return pm, nil
+}
+
+// Encode encodes the known fields followed by the unknown fields, which
+// are written back byte for byte
+func (pm *ParsedMessage) Encode() ([]byte, error) {
+	b, err := Encode(pm.Message)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range pm.UnknownFields {
+		b = protowire.AppendTag(b, protowire.Number(f.ID), protowire.Type(f.WireType))
+		b = append(b, f.Data...)
+	}
+	return b, nil
+}
+
+func inSchema(schema []FieldSpec, tag *Tag) bool {
+	for _, s := range schema {
+		if s.ID != tag.fieldID {
+			continue
+		}
+		return s.Type.wireType() == tag.typ || (s.Type == TypeMessage && tag.typ == SGroup)
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package main
+
+import "fmt"
+
+type (
+	FieldSpec struct {
+		ID       uint64
+		Required bool
+		Type     FieldType
+		Repeated bool
+	}
+
+	ValidationError struct {
+		FieldID  uint64
+		Expected string
+		Actual   string
+		Message  string
+	}
+)
+
+func (e ValidationError) Error() string {
+	return e.Message
+}
+
+func Validate(m *Message, spec []FieldSpec) []ValidationError {
+	var errs []ValidationError
+	for _, s := range spec {
+		var fields []Field
+		if m != nil {
+			fields = (*m)[s.ID]
+		}
+		if len(fields) == 0 {
+			if s.Required {
+				errs = append(errs, ValidationError{
+					FieldID:  s.ID,
+					Expected: "present",
+					Actual:   "missing",
+					Message:  fmt.Sprintf("Required field %d is missing", s.ID),
+				})
+			}
+			continue
+		}
+		if !s.Repeated && len(fields) > 1 {
+			errs = append(errs, ValidationError{
+				FieldID:  s.ID,
+				Expected: "1 value",
+				Actual:   fmt.Sprintf("%d values", len(fields)),
+				Message:  fmt.Sprintf("Field %d is not repeated but has %d values", s.ID, len(fields)),
+			})
+		}
+		for i, f := range fields {
+			if !typeCompatible(s.Type, f) {
+				errs = append(errs, ValidationError{
+					FieldID:  s.ID,
+					Expected: s.Type.String(),
+					Actual:   fieldKind(f),
+					Message:  fmt.Sprintf("Field %d value %d is %s, which cannot hold a %s", s.ID, i, fieldKind(f), s.Type),
+				})
+			}
+		}
+	}
+	return errs
+}
+
+func typeCompatible(t FieldType, f Field) bool {
+	switch t.wireType() {
+	case LengthDelim:
+		if t == TypeMessage {
+			return f.message != nil
+		}
+		return f.string != nil || f.bytes != nil || (f.message != nil && f.wireType != SGroup)
+	default:
+		return f.numeric != nil && f.wireType == t.wireType()
+	}
+}
+
+func fieldKind(f Field) string {
+	switch {
+	case f.numeric != nil && f.wireType == B32:
+		return "fixed32"
+	case f.numeric != nil && f.wireType == B64:
+		return "fixed64"
+	case f.numeric != nil:
+		return "varint"
+	case f.string != nil:
+		return "string"
+	case f.bytes != nil:
+		return "bytes"
+	case f.message != nil && f.wireType == SGroup:
+		return "group"
+	case f.message != nil:
+		return "message"
+	default:
+		return "empty"
+	}
+}
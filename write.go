@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// The Write* functions stream their output, stopping at the first write
+// error; the matching Render* functions are built on them
+
+func WriteMessage(w io.Writer, m *Message, opts RenderOptions) error {
+	out := &output{w: w}
+	writeMessage(out, m, opts, "")
+	return out.err
+}
+
+func WritePretty(w io.Writer, m *Message) error {
+	return WriteMessage(w, m, prettyOptions)
+}
+
+func WriteText(w io.Writer, m *Message) error {
+	out := &output{w: w}
+	writeText(out, m, "")
+	return out.err
+}
+
+var prettyOptions = RenderOptions{
+	Sorted: true,
+	Indent: "  ",
+}
+
+func writeMessage(out *output, m *Message, opts RenderOptions, indent string) {
+	if m == nil || len(*m) == 0 {
+		io.WriteString(out, "{}")
+		return
+	}
+	inner := indent + opts.Indent
+	first := true
+	writeKey := func(key string) {
+		if !first {
+			io.WriteString(out, ",")
+		}
+		first = false
+		writeNewline(out, opts, inner)
+		io.WriteString(out, quoteString(key))
+		if opts.Indent != "" {
+			io.WriteString(out, ": ")
+		} else {
+			io.WriteString(out, ":")
+		}
+	}
+	io.WriteString(out, "{")
+	for _, id := range opts.fieldIDs(*m) {
+		if out.err != nil {
+			return
+		}
+		fields := (*m)[id]
+		if len(fields) == 1 {
+			writeKey(opts.rangeKey(opts.fieldKey(id), fields[0]))
+			writeField(out, id, fields[0], opts, inner)
+		} else if opts.IndexRepeated || (opts.ShowByteRanges && fields[0].span != nil) {
+			for i, f := range fields {
+				key := opts.fieldKey(id)
+				if opts.IndexRepeated {
+					key = fmt.Sprintf("%s[%d]", key, i)
+				}
+				writeKey(opts.rangeKey(key, f))
+				writeField(out, id, f, opts, inner)
+			}
+		} else {
+			writeKey(opts.fieldKey(id))
+			io.WriteString(out, "[")
+			for i, f := range fields {
+				if i > 0 {
+					io.WriteString(out, ",")
+				}
+				writeNewline(out, opts, inner+opts.Indent)
+				writeField(out, id, f, opts, inner+opts.Indent)
+			}
+			writeNewline(out, opts, inner)
+			io.WriteString(out, "]")
+		}
+	}
+	writeNewline(out, opts, indent)
+	io.WriteString(out, "}")
+}
+
+// rangeKey appends the value's byte range to key, as in "1[2:7]"
+func (opts RenderOptions) rangeKey(key string, f Field) string {
+	if start, end, ok := f.ByteRange(); ok && opts.ShowByteRanges {
+		return fmt.Sprintf("%s[%d:%d]", key, start, end)
+	}
+	return key
+}
+
+func writeField(out *output, id uint64, f Field, opts RenderOptions, indent string) {
+	if f.message != nil {
+		writeMessage(out, f.message, opts, indent)
+		return
+	}
+	io.WriteString(out, RenderFieldWithOptions(id, f, opts))
+}
+
+func writeNewline(out *output, opts RenderOptions, indent string) {
+	if opts.Indent != "" {
+		io.WriteString(out, "\n"+indent)
+	}
+}
+
+func writeText(out *output, m *Message, indent string) {
+	if m == nil {
+		return
+	}
+	for _, id := range m.FieldIDs() {
+		for _, f := range (*m)[id] {
+			if out.err != nil {
+				return
+			}
+			switch {
+			case f.numeric != nil:
+				fmt.Fprintf(out, "%s%d: %d\n", indent, id, *f.numeric)
+			case f.string != nil:
+				fmt.Fprintf(out, "%s%d: %s\n", indent, id, quoteText([]byte(*f.string), true))
+			case f.bytes != nil:
+				fmt.Fprintf(out, "%s%d: %s\n", indent, id, quoteText(*f.bytes, false))
+			case f.message != nil:
+				fmt.Fprintf(out, "%s%d {\n", indent, id)
+				writeText(out, f.message, indent+"  ")
+				fmt.Fprintf(out, "%s}\n", indent)
+			}
+		}
+	}
+}